@@ -0,0 +1,15 @@
+package worker
+
+// AlertRule defines a threshold rule evaluated against
+// the stats of a server.
+type AlertRule struct {
+	// Name is a unique, user readable, identifier of the rule.
+	// Rules defined by a preset override the ones with the same
+	// name defined on the worker configuration.
+	Name string `hcl:"name,label" json:"name"`
+	// Rule is the expression to be evaluated, e.g. "memory_percentage > 95 for 2m".
+	Rule string `hcl:"rule" json:"rule"`
+	// Notifiers defines which notifiers to use when the alert is firing or resolved.
+	// Defaults to the "log" notifier.
+	Notifiers []string `hcl:"notifiers,optional" json:"notifiers,omitempty"`
+}
@@ -0,0 +1,269 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/PanelMc/worker"
+)
+
+// State represents whether an alert is firing or not.
+type State string
+
+const (
+	// StateFiring indicates the alert condition held for the configured duration.
+	StateFiring State = "firing"
+	// StateResolved indicates the alert condition no longer holds.
+	StateResolved State = "resolved"
+)
+
+// Alert holds the information sent to the notifiers when
+// a rule changes its state.
+type Alert struct {
+	ServerID string  `json:"server_id"`
+	Rule     string  `json:"rule"`
+	Name     string  `json:"name"`
+	State    State   `json:"state"`
+	Value    float64 `json:"value"`
+	// Since is the time at which the condition started holding
+	Since time.Time `json:"since"`
+	Time  time.Time `json:"time"`
+
+	Container worker.Container `json:"-"`
+}
+
+// Message returns a user readable description of the alert.
+func (a Alert) Message() string {
+	if a.State == StateFiring {
+		return fmt.Sprintf("Alert '%s' firing: %s (current value %.2f)", a.Name, a.Rule, a.Value)
+	}
+
+	return fmt.Sprintf("Alert '%s' resolved: %s (current value %.2f)", a.Name, a.Rule, a.Value)
+}
+
+type ruleState struct {
+	rule   *Rule
+	since  time.Time
+	firing bool
+}
+
+// evaluate updates the state with the given stats, and returns
+// whether the alert changed its state.
+func (s *ruleState) evaluate(stats *worker.ContainerStats, now time.Time) (bool, float64) {
+	matches, value := s.rule.Matches(stats)
+	if !matches {
+		s.since = time.Time{}
+		if s.firing {
+			s.firing = false
+			return true, value
+		}
+
+		return false, value
+	}
+
+	if s.since.IsZero() {
+		s.since = now
+	}
+
+	if !s.firing && now.Sub(s.since) >= s.rule.For {
+		s.firing = true
+		return true, value
+	}
+
+	return false, value
+}
+
+// Engine evaluates the alert rules against the stats
+// stream of each watched server.
+type Engine struct {
+	sync.Mutex
+
+	rules     []*Rule
+	notifiers map[string]Notifier
+	watching  map[string]*watcher
+}
+
+// watcher is the evaluation of the rules of a server.
+type watcher struct {
+	cancel context.CancelFunc
+}
+
+// NewEngine creates a new alert engine with the given global rules.
+// The "log" and "console" notifiers are always available.
+func NewEngine(rules []worker.AlertRule, notifiers map[string]Notifier) (*Engine, error) {
+	parsed, err := parseRules(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	n := map[string]Notifier{
+		"log":     LogNotifier,
+		"console": ConsoleNotifier,
+	}
+	for name, notifier := range notifiers {
+		n[name] = notifier
+	}
+
+	for _, rule := range parsed {
+		for _, name := range rule.Notifiers {
+			if _, ok := n[name]; !ok {
+				return nil, fmt.Errorf("invalid alert rule '%s': unknown notifier '%s'", rule.Name, name)
+			}
+		}
+	}
+
+	return &Engine{
+		rules:     parsed,
+		notifiers: n,
+		watching:  make(map[string]*watcher),
+	}, nil
+}
+
+// Watch starts evaluating the alert rules against the stats of the given container.
+// The given rules, usually defined by the server preset, override the global
+// rules with the same name.
+func (e *Engine) Watch(serverID string, c worker.Container, rules ...worker.AlertRule) error {
+	extra, err := parseRules(rules)
+	if err != nil {
+		return err
+	}
+
+	states := make([]*ruleState, 0, len(e.rules)+len(extra))
+	names := make(map[string]bool)
+	for _, rule := range extra {
+		for _, name := range rule.Notifiers {
+			if _, ok := e.notifiers[name]; !ok {
+				return fmt.Errorf("invalid alert rule '%s': unknown notifier '%s'", rule.Name, name)
+			}
+		}
+
+		names[rule.Name] = true
+		states = append(states, &ruleState{rule: rule})
+	}
+	for _, rule := range e.rules {
+		if !names[rule.Name] {
+			states = append(states, &ruleState{rule: rule})
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &watcher{cancel}
+
+	e.Lock()
+	if _, ok := e.watching[serverID]; ok {
+		e.Unlock()
+		cancel()
+		return fmt.Errorf("server '%s' already being watched", serverID)
+	}
+	e.watching[serverID] = w
+	e.Unlock()
+
+	stats, err := c.StatsChanContext(ctx)
+	if err != nil {
+		e.forget(serverID, w)
+		cancel()
+		return err
+	}
+
+	go e.watch(ctx, w, serverID, c, states, stats)
+
+	return nil
+}
+
+// Unwatch stops evaluating the alert rules for the given server.
+func (e *Engine) Unwatch(serverID string) {
+	e.Lock()
+	defer e.Unlock()
+
+	if w, ok := e.watching[serverID]; ok {
+		w.cancel()
+		delete(e.watching, serverID)
+	}
+}
+
+// UnwatchAll stops evaluating the alert rules for every server.
+func (e *Engine) UnwatchAll() {
+	e.Lock()
+	defer e.Unlock()
+
+	for serverID, w := range e.watching {
+		w.cancel()
+		delete(e.watching, serverID)
+	}
+}
+
+func (e *Engine) forget(serverID string, w *watcher) {
+	e.Lock()
+	defer e.Unlock()
+
+	if e.watching[serverID] == w {
+		delete(e.watching, serverID)
+	}
+}
+
+func (e *Engine) watch(ctx context.Context, w *watcher, serverID string, c worker.Container, states []*ruleState, stats <-chan *worker.ContainerStats) {
+	defer e.forget(serverID, w)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case s, ok := <-stats:
+			if !ok {
+				return
+			}
+
+			now := time.Now()
+			for _, state := range states {
+				since := state.since
+				changed, value := state.evaluate(s, now)
+				if !changed {
+					continue
+				}
+
+				alert := Alert{
+					ServerID:  serverID,
+					Rule:      state.rule.String(),
+					Name:      state.rule.Name,
+					State:     StateResolved,
+					Value:     value,
+					Since:     since,
+					Time:      now,
+					Container: c,
+				}
+				if state.firing {
+					alert.State = StateFiring
+					alert.Since = state.since
+				}
+
+				e.notify(state.rule, alert)
+			}
+		}
+	}
+}
+
+func (e *Engine) notify(rule *Rule, alert Alert) {
+	for _, name := range rule.Notifiers {
+		go func(name string, notifier Notifier) {
+			if err := notifier.Notify(alert); err != nil {
+				logger.Errorf("Failed to notify '%s' about alert '%s': %s", name, alert.Name, err)
+			}
+		}(name, e.notifiers[name])
+	}
+}
+
+func parseRules(rules []worker.AlertRule) ([]*Rule, error) {
+	parsed := make([]*Rule, 0, len(rules))
+	for _, r := range rules {
+		rule, err := ParseRule(r)
+		if err != nil {
+			return nil, err
+		}
+
+		parsed = append(parsed, rule)
+	}
+
+	return parsed, nil
+}
@@ -0,0 +1,83 @@
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Notifier is notified whenever an alert starts firing or is resolved.
+type Notifier interface {
+	Notify(alert Alert) error
+}
+
+// NotifierFunc allows the use of ordinary functions as notifiers.
+type NotifierFunc func(alert Alert) error
+
+// Notify calls f(alert).
+func (f NotifierFunc) Notify(alert Alert) error {
+	return f(alert)
+}
+
+// LogNotifier logs the alert using the worker logger.
+var LogNotifier = NotifierFunc(func(alert Alert) error {
+	entry := logger.WithField("server", alert.ServerID)
+	if alert.State == StateFiring {
+		entry.Warnln(alert.Message())
+	} else {
+		entry.Infoln(alert.Message())
+	}
+
+	return nil
+})
+
+// ConsoleNotifier broadcasts the alert to the server console.
+var ConsoleNotifier = NotifierFunc(func(alert Alert) error {
+	if alert.Container == nil {
+		return nil
+	}
+
+	alert.Container.Logger().Warnln(alert.Message())
+	return nil
+})
+
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a notifier which sends the alert
+// as a JSON POST request to the given url.
+func NewWebhookNotifier(url string) Notifier {
+	return &webhookNotifier{
+		url: url,
+		client: &http.Client{
+			Timeout: time.Second * 10,
+		},
+	}
+}
+
+func (n *webhookNotifier) Notify(alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	res, err := n.client.Post(n.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %s", res.Status)
+	}
+
+	return nil
+}
+
+var logger = logrus.WithField("context", "alert")
@@ -0,0 +1,115 @@
+package alert
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PanelMc/worker"
+)
+
+// Rule is a parsed worker.AlertRule, ready to be evaluated.
+type Rule struct {
+	Name      string
+	Metric    string
+	Operator  string
+	Threshold float64
+	// For defines for how long the condition must hold
+	// before the alert is fired.
+	For       time.Duration
+	Notifiers []string
+}
+
+var metrics = map[string]func(*worker.ContainerStats) float64{
+	"cpu_percentage":    func(s *worker.ContainerStats) float64 { return s.CPUPercentage },
+	"memory_percentage": func(s *worker.ContainerStats) float64 { return s.MemoryPercentage },
+	"memory":            func(s *worker.ContainerStats) float64 { return float64(s.Memory) },
+	"memory_limit":      func(s *worker.ContainerStats) float64 { return float64(s.MemoryLimit) },
+	"network_download":  func(s *worker.ContainerStats) float64 { return float64(s.NetworkDownload) },
+	"network_upload":    func(s *worker.ContainerStats) float64 { return float64(s.NetworkUpload) },
+	"disc_read":         func(s *worker.ContainerStats) float64 { return float64(s.DiscRead) },
+	"disc_write":        func(s *worker.ContainerStats) float64 { return float64(s.DiscWrite) },
+	"disk_usage":        func(s *worker.ContainerStats) float64 { return float64(s.DiskUsage) },
+	"disk_percentage": func(s *worker.ContainerStats) float64 {
+		if s.DiskQuota == 0 {
+			return 0
+		}
+		return float64(s.DiskUsage) / float64(s.DiskQuota) * 100
+	},
+}
+
+var operators = map[string]func(a, b float64) bool{
+	">":  func(a, b float64) bool { return a > b },
+	">=": func(a, b float64) bool { return a >= b },
+	"<":  func(a, b float64) bool { return a < b },
+	"<=": func(a, b float64) bool { return a <= b },
+	"==": func(a, b float64) bool { return a == b },
+	"!=": func(a, b float64) bool { return a != b },
+}
+
+// ParseRule parses the expression of the given rule.
+//
+// The expression has the format `<metric> <operator> <threshold> [for <duration>]`,
+// where metric is one of the json fields of worker.ContainerStats.
+func ParseRule(rule worker.AlertRule) (*Rule, error) {
+	fields := strings.Fields(rule.Rule)
+	if len(fields) != 3 && len(fields) != 5 {
+		return nil, fmt.Errorf("invalid alert rule '%s': expected '<metric> <operator> <threshold> [for <duration>]'", rule.Name)
+	}
+
+	if _, ok := metrics[fields[0]]; !ok {
+		return nil, fmt.Errorf("invalid alert rule '%s': unknown metric '%s'", rule.Name, fields[0])
+	}
+
+	if _, ok := operators[fields[1]]; !ok {
+		return nil, fmt.Errorf("invalid alert rule '%s': unknown operator '%s'", rule.Name, fields[1])
+	}
+
+	threshold, err := strconv.ParseFloat(fields[2], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid alert rule '%s': invalid threshold '%s'", rule.Name, fields[2])
+	}
+
+	var duration time.Duration
+	if len(fields) == 5 {
+		if fields[3] != "for" {
+			return nil, fmt.Errorf("invalid alert rule '%s': expected 'for', got '%s'", rule.Name, fields[3])
+		}
+
+		duration, err = time.ParseDuration(fields[4])
+		if err != nil {
+			return nil, fmt.Errorf("invalid alert rule '%s': %w", rule.Name, err)
+		}
+	}
+
+	notifiers := rule.Notifiers
+	if len(notifiers) == 0 {
+		notifiers = []string{"log"}
+	}
+
+	return &Rule{
+		Name:      rule.Name,
+		Metric:    fields[0],
+		Operator:  fields[1],
+		Threshold: threshold,
+		For:       duration,
+		Notifiers: notifiers,
+	}, nil
+}
+
+// Matches returns whether the condition of the rule holds for the given stats,
+// along with the value of the metric.
+func (r *Rule) Matches(stats *worker.ContainerStats) (bool, float64) {
+	value := metrics[r.Metric](stats)
+
+	return operators[r.Operator](value, r.Threshold), value
+}
+
+func (r *Rule) String() string {
+	if r.For > 0 {
+		return fmt.Sprintf("%s %s %g for %s", r.Metric, r.Operator, r.Threshold, r.For)
+	}
+
+	return fmt.Sprintf("%s %s %g", r.Metric, r.Operator, r.Threshold)
+}
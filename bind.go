@@ -0,0 +1,149 @@
+package worker
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"code.cloudfoundry.org/bytefmt"
+)
+
+var bindBasePaths struct {
+	sync.RWMutex
+	paths []string
+}
+
+// SetBindBasePaths restricts the host directories of the binds to
+// be inside one of the given paths. No paths means no restriction.
+func SetBindBasePaths(paths []string) {
+	bindBasePaths.Lock()
+	defer bindBasePaths.Unlock()
+
+	bindBasePaths.paths = make([]string, len(paths))
+	for i, p := range paths {
+		bindBasePaths.paths[i] = filepath.Clean(p)
+	}
+}
+
+var propagations = map[string]bool{
+	"private": true, "rprivate": true,
+	"shared": true, "rshared": true,
+	"slave": true, "rslave": true,
+}
+
+// Validate checks whether the bind, with the given resolved host directory, is valid.
+func (b ContainerBind) Validate(hostDir string) error {
+	if b.Volume == "" || !path.IsAbs(b.Volume) {
+		return fmt.Errorf("invalid bind volume '%s': must be an absolute path", b.Volume)
+	}
+
+	if b.Mode != "" && b.Mode != "ro" && b.Mode != "rw" {
+		return fmt.Errorf("invalid bind mode '%s' for '%s': expected ro or rw", b.Mode, b.Volume)
+	}
+
+	switch b.BindType() {
+	case BindTypeBind:
+		if b.Propagation != "" && !propagations[b.Propagation] {
+			return fmt.Errorf("invalid bind propagation '%s' for '%s'", b.Propagation, b.Volume)
+		}
+
+		if b.SELinux != "" && b.SELinux != "z" && b.SELinux != "Z" {
+			return fmt.Errorf("invalid bind selinux label '%s' for '%s': expected z or Z", b.SELinux, b.Volume)
+		}
+
+		return validateHostDir(hostDir)
+	case BindTypeVolume:
+		if b.VolumeName == "" {
+			return fmt.Errorf("invalid bind for '%s': volume_name is required for volumes", b.Volume)
+		}
+	case BindTypeTmpfs:
+		if b.Mode == "ro" {
+			return fmt.Errorf("invalid bind for '%s': tmpfs can't be read only", b.Volume)
+		}
+
+		if b.Size != "" {
+			if _, err := bytefmt.ToBytes(b.Size); err != nil {
+				return fmt.Errorf("invalid tmpfs size '%s' for '%s': %w", b.Size, b.Volume, err)
+			}
+		}
+	default:
+		return fmt.Errorf("invalid bind type '%s' for '%s': expected bind, volume or tmpfs", b.Type, b.Volume)
+	}
+
+	return nil
+}
+
+func validateHostDir(hostDir string) error {
+	if !filepath.IsAbs(hostDir) {
+		return fmt.Errorf("invalid bind host directory '%s': must be an absolute path", hostDir)
+	}
+
+	bindBasePaths.RLock()
+	defer bindBasePaths.RUnlock()
+
+	if len(bindBasePaths.paths) == 0 {
+		return nil
+	}
+
+	// Resolve symlinks, so they can't be used to escape the base path
+	dir, err := resolvePath(hostDir)
+	if err != nil {
+		return fmt.Errorf("invalid bind host directory '%s': %w", hostDir, err)
+	}
+
+	for _, base := range bindBasePaths.paths {
+		resolved, err := resolvePath(base)
+		if err != nil {
+			return fmt.Errorf("invalid bind base path '%s': %w", base, err)
+		}
+
+		rel, err := filepath.Rel(resolved, dir)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid bind host directory '%s': must be inside %s", hostDir, strings.Join(bindBasePaths.paths, ", "))
+}
+
+// resolvePath resolves the symlinks of the deepest existing ancestor of
+// the path, appending the elements that don't exist yet.
+func resolvePath(p string) (string, error) {
+	p = filepath.Clean(p)
+	missing := ""
+	for {
+		resolved, err := filepath.EvalSymlinks(p)
+		if err == nil {
+			return filepath.Join(resolved, missing), nil
+		}
+
+		// Only walk up missing elements, e.g. not dangling symlinks
+		if _, statErr := os.Lstat(p); !os.IsNotExist(statErr) {
+			return "", err
+		}
+
+		parent := filepath.Dir(p)
+		if parent == p {
+			return filepath.Join(p, missing), nil
+		}
+		missing = filepath.Join(filepath.Base(p), missing)
+		p = parent
+	}
+}
+
+// validateBindTargets checks that no two binds mount on the same container path.
+func validateBindTargets(binds []ContainerBind) error {
+	targets := make(map[string]bool)
+	for _, bind := range binds {
+		target := path.Clean(bind.Volume)
+		if targets[target] {
+			return fmt.Errorf("invalid bind volume '%s': mounted more than once", bind.Volume)
+		}
+		targets[target] = true
+	}
+
+	return nil
+}
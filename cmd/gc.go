@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+
+	"github.com/PanelMc/worker/engine"
+	"github.com/PanelMc/worker/gc"
+	"github.com/PanelMc/worker/image"
+	"github.com/PanelMc/worker/infra"
+)
+
+// runGC runs the garbage collection on demand, printing its report.
+func runGC(args []string) error {
+	flags := flag.NewFlagSet("gc", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "only report what would be removed")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	infra.InitializeLogger()
+
+	cfg, err := infra.InitializeConfig()
+	if err != nil {
+		return err
+	}
+	infra.InitializeSpecStore(cfg)
+
+	runtime, err := engine.Connect(context.Background())
+	if err != nil {
+		return err
+	}
+	defer runtime.Close()
+
+	opts, err := gcOptions(cfg)
+	if err != nil {
+		return err
+	}
+	opts.DryRun = opts.DryRun || *dryRun
+
+	report, err := gc.Run(context.Background(), runtime.Client(), opts)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+
+	return nil
+}
+
+// gcOptions returns the garbage collection options,
+// keeping the images used by the presets.
+func gcOptions(cfg infra.Config) (gc.Options, error) {
+	images, err := presetImages(cfg)
+	if err != nil {
+		return gc.Options{}, err
+	}
+
+	var imported []string
+	if cfg.ImageImportFolder != "" {
+		if imported, err = image.ImportedImages(cfg.ImageImportFolder); err != nil {
+			return gc.Options{}, err
+		}
+	}
+
+	return gc.Options{
+		Images:   images,
+		Imported: imported,
+		DryRun:   cfg.GCDryRun,
+	}, nil
+}
+
+// startGC schedules the garbage collection, if enabled.
+// The returned function stops the schedule.
+func startGC(cfg infra.Config, runtime *engine.Runtime) (stop func()) {
+	if cfg.GCInterval <= 0 {
+		return func() {}
+	}
+
+	return gc.Schedule(runtime, cfg.GCInterval, func() (gc.Options, error) {
+		return gcOptions(cfg)
+	})
+}
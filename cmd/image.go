@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/PanelMc/worker/engine"
+	"github.com/PanelMc/worker/image"
+	"github.com/PanelMc/worker/infra"
+	"github.com/PanelMc/worker/io"
+	"github.com/sirupsen/logrus"
+)
+
+// imageImportInterval defines how often the image import folder is checked
+const imageImportInterval = 30 * time.Second
+
+const imageUsage = `Usage:
+  worker image load <file.tar>...
+  worker image save -o <file.tar> [-presets] [image...]`
+
+// runImage runs the image subcommands, to load and save image tarballs
+// for nodes without registry access.
+func runImage(args []string) error {
+	if len(args) == 0 {
+		return errors.New(imageUsage)
+	}
+
+	infra.InitializeLogger()
+
+	ctx := context.Background()
+	runtime, err := engine.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer runtime.Close()
+	cli := runtime.Client()
+
+	switch args[0] {
+	case "load":
+		if len(args) < 2 {
+			return errors.New(imageUsage)
+		}
+
+		for _, file := range args[1:] {
+			if _, err := image.Load(ctx, cli, file); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "save":
+		flags := flag.NewFlagSet("save", flag.ContinueOnError)
+		output := flags.String("o", "", "tarball to write the images to")
+		presets := flags.Bool("presets", false, "save the images used by the presets")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *output == "" {
+			return errors.New(imageUsage)
+		}
+
+		images := flags.Args()
+		if *presets {
+			cfg, err := infra.InitializeConfig()
+			if err != nil {
+				return err
+			}
+
+			presetImages, err := presetImages(cfg)
+			if err != nil {
+				return err
+			}
+			images = append(images, presetImages...)
+		}
+
+		return image.Save(ctx, cli, images, *output)
+	default:
+		return fmt.Errorf("unknown image command '%s'\n%s", args[0], imageUsage)
+	}
+}
+
+// presetImages returns the images used by the presets, without duplicates.
+func presetImages(cfg infra.Config) ([]string, error) {
+	presets, err := io.LoadPresets(cfg.PresetsFolder)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	images := make([]string, 0, len(presets))
+	for _, preset := range presets {
+		if preset.ContainerImage != nil && !seen[preset.ContainerImage.ID] {
+			seen[preset.ContainerImage.ID] = true
+			images = append(images, preset.ContainerImage.ID)
+		}
+	}
+	sort.Strings(images)
+
+	return images, nil
+}
+
+// startImageImport watches the image import folder, if configured.
+// The returned function stops watching.
+func startImageImport(cfg infra.Config, runtime *engine.Runtime) (stop func()) {
+	if cfg.ImageImportFolder == "" {
+		return func() {}
+	}
+
+	stop, err := image.WatchImports(runtime, cfg.ImageImportFolder, imageImportInterval)
+	if err != nil {
+		logrus.Errorf("Failed to watch the image import folder: %s", err)
+		return func() {}
+	}
+
+	return stop
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/PanelMc/worker"
+	"github.com/PanelMc/worker/container"
+	"github.com/PanelMc/worker/infra"
+	"github.com/PanelMc/worker/io"
+)
+
+const renderUsage = `Usage:
+  worker render -preset <name> [-id <server>] [-format json|hcl]`
+
+// runRender prints the docker create request of a server, created with the
+// config defaults and the given preset, without touching docker.
+func runRender(args []string) error {
+	flags := flag.NewFlagSet("render", flag.ContinueOnError)
+	presetName := flags.String("preset", "", "preset to create the server with")
+	id := flags.String("id", "", "ID of the server, overriding the preset one")
+	format := flags.String("format", "json", "output format, json or hcl")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *presetName == "" || (*format != "json" && *format != "hcl") {
+		return errors.New(renderUsage)
+	}
+
+	cfg, err := infra.InitializeConfig()
+	if err != nil {
+		return err
+	}
+	infra.InitializePermissions(cfg)
+	if err := infra.InitializeAdmission(cfg); err != nil {
+		return err
+	}
+	if err := infra.InitializePortPool(cfg); err != nil {
+		return err
+	}
+	if err := infra.InitializeBindBasePaths(cfg); err != nil {
+		return err
+	}
+
+	presets, err := io.LoadPresets(cfg.PresetsFolder)
+	if err != nil {
+		return err
+	}
+	preset, ok := presets[*presetName]
+	if !ok {
+		return fmt.Errorf("unknown preset '%s'", *presetName)
+	}
+	if *id != "" {
+		preset.ServerID = *id
+	}
+
+	opts := make([]worker.ContainerOpts, 0, 2)
+	if cfg.Server != nil {
+		opts = append(opts, func(o *worker.ContainerOptions) {
+			o.Binds = append(o.Binds, cfg.Server.Binds...)
+		})
+	}
+	opts = append(opts, worker.WithPreset(preset))
+
+	req, err := container.Render(opts...)
+	if err != nil {
+		return err
+	}
+
+	var out []byte
+	if *format == "hcl" {
+		out, err = io.EncodeJSONAsHCL(req)
+	} else {
+		out, err = json.MarshalIndent(req, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(append(out, '\n'))
+	return err
+}
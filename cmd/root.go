@@ -1,20 +1,121 @@
 package cmd
 
 import (
-	"fmt"
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/PanelMc/worker/alert"
+	"github.com/PanelMc/worker/engine"
 	"github.com/PanelMc/worker/infra"
+	"github.com/PanelMc/worker/node"
+	"github.com/sirupsen/logrus"
 )
 
+// Execute runs the subcommand in the given args, or the worker if there is none.
+func Execute(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "image":
+			return runImage(args[1:])
+		case "gc":
+			return runGC(args[1:])
+		case "render":
+			return runRender(args[1:])
+		}
+	}
+
+	return Run()
+}
+
 func Run() (err error) {
 	infra.InitializeLogger()
 
+	// The background loops are stopped before closing the docker client they use
+	var stops []func()
+	defer func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			stops[i]()
+		}
+		engine.Close()
+	}()
+
 	var cfg infra.Config
 	cfg, err = infra.InitializeConfig()
 	if err != nil {
 		return
 	}
-	fmt.Printf("Config: %#v\n", cfg)
+	logrus.Debugf("Config: %#v", cfg)
+
+	infra.InitializePermissions(cfg)
+
+	var alerts *alert.Engine
+	if alerts, err = infra.InitializeAlerts(cfg); err != nil {
+		return
+	}
+	stops = append(stops, alerts.UnwatchAll)
+
+	if err = infra.InitializeAdmission(cfg); err != nil {
+		return
+	}
+
+	if err = infra.InitializePortPool(cfg); err != nil {
+		return
+	}
+
+	if err = infra.InitializeBindBasePaths(cfg); err != nil {
+		return
+	}
+
+	infra.InitializeSpecStore(cfg)
+
+	stops = append(stops, infra.InitializeDiskMonitor(cfg))
+
+	infra.InitializeUserns(cfg)
+
+	if err = infra.InitializeRegistries(cfg); err != nil {
+		return
+	}
+
+	var runtime *engine.Runtime
+	if runtime, err = infra.InitializeRuntime(cfg); err != nil {
+		return
+	}
+
+	logNodeInfo(cfg, runtime)
+
+	stops = append(stops, startImageImport(cfg, runtime))
+
+	stops = append(stops, infra.InitializeImageUpdateCheck(cfg))
+
+	stops = append(stops, startGC(cfg, runtime))
+
+	waitShutdown()
 
 	return
 }
+
+// waitShutdown blocks until the worker is interrupted or terminated.
+func waitShutdown() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	sig := <-signals
+	logrus.Infof("Received %s, shutting down...", sig)
+}
+
+func logNodeInfo(cfg infra.Config, runtime *engine.Runtime) {
+	var dataDirs []string
+	if cfg.Server != nil {
+		dataDirs = node.DataDirs(cfg.Server.Binds)
+	}
+
+	info, err := node.GetInfo(context.Background(), runtime.Client(), dataDirs)
+	if err != nil {
+		logrus.Errorf("Failed to read the node info: %s", err)
+		return
+	}
+	logrus.Infof("Node: %#v", info)
+}
@@ -1,21 +1,55 @@
 package worker
 
 import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+
 	"github.com/sirupsen/logrus"
 )
 
 // Container represents the container the server is running on.
 type Container interface {
+	// Name returns the unique, user readable, identifier of the container
+	Name() string
+	// Options returns the options used to create the container
+	Options() ContainerOptions
 	// Start starts the container if not running already
 	Start() error
+	// StartContext starts the container, cancelling the request when the context is done
+	StartContext(ctx context.Context) error
 	// Stop stopps the container if running
 	Stop() error
+	// StopContext stops the container, cancelling the request when the context is done
+	StopContext(ctx context.Context) error
+	// Remove removes the container, stopping it if running
+	Remove() error
 	// Exec executes a command on the container
 	Exec(cmd string) error
+	// ExecContext executes a command, cancelling it when the context is done
+	ExecContext(ctx context.Context, cmd string) error
+	// UpdateResources updates the resources of the container without recreating it.
+	// Changes to the JVM flags generated from the memory limit are applied by Recreate.
+	UpdateResources(update ResourceUpdate) ([]ResourceChange, error)
+	// CheckImageUpdate compares the pinned image with the one its tag refers to on the node
+	CheckImageUpdate() (ImageStatus, error)
+	// UpdateImage pulls the image, recreating the container if it changed,
+	// and restores its previous power state
+	UpdateImage() (ImageStatus, error)
+	// Recreate recreates the container with the updated options, if they differ
+	// from the stored spec, keeping its name and binds, and restores its
+	// previous power state
+	Recreate(updated ContainerOptions) ([]SpecChange, error)
 	// Stats returns the last stats obtained from the container
 	Stats() (ContainerStats, error)
+	// StatsContext returns the stats, cancelling the request when the context is done
+	StatsContext(ctx context.Context) (ContainerStats, error)
 	// StatsChan returns a channel that receives the container stats
 	StatsChan() (<-chan *ContainerStats, error)
+	// StatsChanContext returns a channel that receives the container stats,
+	// closed once the context is done. Each call gets its own channel.
+	StatsChanContext(ctx context.Context) (<-chan *ContainerStats, error)
 	// Status says whether the server is running or not
 	Status() Status
 	// Logger returns the logger used by the server
@@ -43,6 +77,39 @@ type ContainerStats struct {
 	DiscRead uint64 `json:"disc_read"`
 	// Disc write
 	DiscWrite uint64 `json:"disc_write"`
+	// Disk space used by the bind host directories, in bytes,
+	// as of the last disk scan
+	DiskUsage uint64 `json:"disk_usage"`
+	// Disk quota in bytes, 0 if unlimited
+	DiskQuota uint64 `json:"disk_quota"`
+}
+
+// ResourceUpdate holds the resources to update on a container.
+// Nil values are left unchanged.
+type ResourceUpdate struct {
+	// Memory overrides the non empty memory values
+	Memory *ContainerMemory `json:"memory,omitempty"`
+	// CPU replaces the CPU limits
+	CPU *ContainerCPU `json:"cpu,omitempty"`
+}
+
+// ResourceChange describes a resource changed by a ResourceUpdate.
+type ResourceChange struct {
+	Resource string `json:"resource"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	// RequiresRestart indicates the change only takes full effect
+	// after the server is restarted
+	RequiresRestart bool   `json:"requires_restart"`
+	Reason          string `json:"reason,omitempty"`
+}
+
+// SpecChange describes an option changed between two container specs,
+// with the values in their json representation.
+type SpecChange struct {
+	Option string `json:"option"`
+	From   string `json:"from"`
+	To     string `json:"to"`
 }
 
 // ContainerOptions holds the options used to create a new container
@@ -52,19 +119,205 @@ type ContainerOptions struct {
 	Image         ContainerImage    `json:"container_image"`
 	Memory        ContainerMemory   `json:"memory"`
 	Network       *ContainerNetwork `json:"network,omitempty"`
+	CPU           ContainerCPU      `json:"cpu"`
+	IO            ContainerIO       `json:"io"`
+	// PidsLimit limits the number of processes in the container, 0 means unlimited
+	PidsLimit int64             `json:"pids_limit,omitempty"`
+	Ulimits   []ContainerUlimit `json:"ulimits,omitempty"`
+	// JVM defines how to generate the JVM flags, nil for non java servers
+	JVM *ContainerJVM `json:"jvm,omitempty"`
+	// Env defines the environment variables of the container.
+	// Values support template variables, e.g. {port.game}.
+	Env map[string]string `json:"env,omitempty"`
+	// Owner of the bind host directories, nil to keep the worker user
+	Owner *ContainerOwner `json:"owner,omitempty"`
+	// Disk defines the disk quota of the bind host directories
+	Disk *ContainerDisk `json:"disk,omitempty"`
+	// Security defines the security options, nil for the hardened profile
+	Security *ContainerSecurity `json:"security,omitempty"`
+	// Alerts defines the alert rules of the server, on top of the global ones
+	Alerts []AlertRule `json:"alerts,omitempty"`
+}
+
+// ContainerDisk defines the disk quota of a container, accounting
+// the space used by its bind host directories.
+type ContainerDisk struct {
+	// Quota is the maximum disk space, e.g. "10GB"
+	Quota string `hcl:"quota" json:"quota"`
+	// WarningPercentage is the usage percentage of the quota at
+	// which to warn the server. Defaults to 90, 0 disables the warning.
+	WarningPercentage *float64 `hcl:"warning_percentage,optional" json:"warning_percentage,omitempty"`
+	// BlockStart prevents the server from starting while over quota
+	BlockStart bool `hcl:"block_start,optional" json:"block_start,omitempty"`
+}
+
+// ContainerOwner defines the user owning the data of a container.
+type ContainerOwner struct {
+	UID int `hcl:"uid" json:"uid"`
+	GID int `hcl:"gid" json:"gid"`
 }
 
 type ContainerImage struct {
+	// ID is the image to use, or the tag to build it as
 	ID string `hcl:"id" json:"id"`
+	// Build defines how to build the image, nil to pull it
+	Build *ContainerImageBuild `hcl:"build,block" json:"build,omitempty"`
+	// PullPolicy defines when to pull the image, can be "always",
+	// "if-not-present" or "never". Defaults to "always".
+	PullPolicy string `hcl:"pull_policy,optional" json:"pull_policy,omitempty"`
+	// Pinned is the ID of the image the container was created with,
+	// resolved from the tag at creation
+	Pinned string `json:"pinned,omitempty"`
+	// Digest is the registry digest of the pinned image, if pulled from a registry
+	Digest string `json:"digest,omitempty"`
+}
+
+// ContainerImageBuild defines how to build an image from a local build context.
+type ContainerImageBuild struct {
+	// Context is the build context directory
+	Context string `hcl:"context" json:"context"`
+	// Dockerfile is the path of the Dockerfile, relative to the context.
+	// Defaults to "Dockerfile".
+	Dockerfile string `hcl:"dockerfile,optional" json:"dockerfile,omitempty"`
+	// Args defines the build arguments
+	Args map[string]string `hcl:"args,optional" json:"args,omitempty"`
+}
+
+// DockerfilePath returns the path of the Dockerfile, relative to the context.
+func (b ContainerImageBuild) DockerfilePath() string {
+	if b.Dockerfile == "" {
+		return "Dockerfile"
+	}
+
+	return b.Dockerfile
+}
+
+// Ref returns the reference used to create the container,
+// the pinned image ID if resolved, or the image tag.
+func (i ContainerImage) Ref() string {
+	if i.Pinned != "" {
+		return i.Pinned
+	}
+
+	return i.ID
+}
+
+// ImageStatus compares the pinned image of a container with
+// the image its tag currently refers to.
+type ImageStatus struct {
+	Image  string `json:"image"`
+	Pinned string `json:"pinned"`
+	Digest string `json:"digest,omitempty"`
+	// Latest is the ID of the image the tag refers to on the node
+	Latest          string    `json:"latest"`
+	UpdateAvailable bool      `json:"update_available"`
+	CheckedAt       time.Time `json:"checked_at"`
 }
 
+// ContainerMemory defines the memory limits of the container.
 type ContainerMemory struct {
+	// Limit is the maximum memory the container can use, e.g. "1GB"
 	Limit string `hcl:"limit" json:"limit"`
-	Swap  string `hcl:"swap,optional" json:"swap"`
+	// Swap is the swap the container can use on top of the memory limit,
+	// e.g. "1GB", "unlimited" or "disabled". Defaults to disabled.
+	// Unlike docker's --memory-swap, it doesn't include the memory limit.
+	Swap string `hcl:"swap,optional" json:"swap"`
+	// Reservation is a soft limit, enforced when the host is low on memory.
+	Reservation string `hcl:"reservation,optional" json:"reservation,omitempty"`
+}
+
+// ContainerCPU defines the CPU limits of the container.
+type ContainerCPU struct {
+	// Cores limits how many cores the container can use, e.g. 1.5
+	Cores float64 `hcl:"cores,optional" json:"cores,omitempty"`
+	// Shares is the CPU weight relative to other containers, defaults to 1024
+	Shares int64 `hcl:"shares,optional" json:"shares,omitempty"`
+	// Cpuset pins the container to the given CPUs, e.g. "0-3" or "0,2"
+	Cpuset string `hcl:"cpuset,optional" json:"cpuset,omitempty"`
+}
+
+// ContainerIO defines the block IO limits of the container.
+type ContainerIO struct {
+	// Weight is the block IO weight relative to other containers, between 10 and 1000
+	Weight uint16 `hcl:"weight,optional" json:"weight,omitempty"`
+	// ReadBps limits the read rate from a device
+	ReadBps []ContainerDeviceRate `hcl:"read_bps,block" json:"read_bps,omitempty"`
+	// WriteBps limits the write rate to a device
+	WriteBps []ContainerDeviceRate `hcl:"write_bps,block" json:"write_bps,omitempty"`
+}
+
+// ContainerDeviceRate limits the bytes per second of a device.
+type ContainerDeviceRate struct {
+	// Device is the path of the device, e.g. "/dev/sda"
+	Device string `hcl:"device,label" json:"device"`
+	// Rate is the maximum bytes per second, e.g. "50MB"
+	Rate string `hcl:"rate" json:"rate"`
+}
+
+// ContainerUlimit defines a resource limit of the container processes.
+type ContainerUlimit struct {
+	// Name of the limit, e.g. "nofile"
+	Name string `hcl:"name,label" json:"name"`
+	Soft int64  `hcl:"soft" json:"soft"`
+	Hard int64  `hcl:"hard" json:"hard"`
 }
 
 type ContainerNetwork struct {
 	Binds []ContainerNetworkBind `hcl:"bind,block" json:"expose"`
+	// Allocate requests ports from the node port pool
+	Allocate *ContainerPortAllocation `hcl:"allocate,block" json:"allocate,omitempty"`
+	// Allocated holds the ports allocated from the node port pool
+	Allocated []AllocatedPort `json:"allocated,omitempty"`
+	// Networks defines the docker networks to attach the container to,
+	// instead of the default bridge network
+	Networks []ContainerDockerNetwork `hcl:"docker_network,block" json:"networks,omitempty"`
+}
+
+// ContainerDockerNetwork defines a user-defined docker network. Networks are
+// created when missing, and removed when no longer in use by any container.
+type ContainerDockerNetwork struct {
+	// Name of the network, supporting template variables. e.g. "{id}" for a
+	// network per server, or a fixed name for a network shared by a group
+	Name string `hcl:"name,label" json:"name"`
+	// Internal networks have no access to or from the host
+	Internal bool `hcl:"internal,optional" json:"internal,omitempty"`
+	// Driver used to create the network, defaults to "bridge"
+	Driver string `hcl:"driver,optional" json:"driver,omitempty"`
+	// Aliases are DNS names other containers in the network can use to reach
+	// this one, supporting template variables. The container name is always an alias.
+	Aliases []string `hcl:"aliases,optional" json:"aliases,omitempty"`
+}
+
+// ContainerPortAllocation requests ports from the node port pool.
+type ContainerPortAllocation struct {
+	// Count is the number of ports to allocate
+	Count int `hcl:"count" json:"count"`
+	// Names of the ports, used by the template variables, e.g. {port.game}.
+	// The first port defaults to "game", and the others to their index.
+	Names []string `hcl:"names,optional" json:"names,omitempty"`
+	// Private defines the container port of each allocated port. The first
+	// one defaults to the game port, the container port of the first static
+	// bind or 25565, and the others to the allocated port
+	Private []string `hcl:"private,optional" json:"private,omitempty"`
+	Proto   string   `hcl:"protocol,optional" json:"protocol,omitempty"`
+}
+
+// AllocatedPort is a port allocated from the node port pool.
+type AllocatedPort struct {
+	Name    string `json:"name"`
+	HostIP  string `json:"host_ip,omitempty"`
+	Port    int    `json:"port"`
+	Private string `json:"private,omitempty"`
+	Proto   string `json:"protocol,omitempty"`
+}
+
+// Bind returns the network bind of the allocated port.
+func (p AllocatedPort) Bind() ContainerNetworkBind {
+	return ContainerNetworkBind{
+		Addr:    net.JoinHostPort(p.HostIP, strconv.Itoa(p.Port)),
+		Private: p.Private,
+		Proto:   p.Proto,
+	}
 }
 
 type ContainerNetworkBind struct {
@@ -75,8 +328,44 @@ type ContainerNetworkBind struct {
 
 // ContainerBind defines which volume binds to use.
 type ContainerBind struct {
+	// Type of the mount, can be "bind", "volume" or "tmpfs". Defaults to "bind".
+	Type string `hcl:"type,optional" json:"type,omitempty"`
 	// HostDir defines where to bind the volume on the host machine.
-	HostDir string `hcl:"host_dir" json:"host_dir,omitempty"`
+	HostDir string `hcl:"host_dir,optional" json:"host_dir,omitempty"`
 	// Volume defines the volume to be binded.
 	Volume string `hcl:"volume,optional" json:"volume"`
+	// Mode can be "ro" for read only, or "rw". Defaults to "rw".
+	Mode string `hcl:"mode,optional" json:"mode,omitempty"`
+	// Propagation defines the bind propagation, e.g. "rprivate" or "rshared".
+	Propagation string `hcl:"propagation,optional" json:"propagation,omitempty"`
+	// SELinux relabels the host directory, "z" to share it between
+	// containers or "Z" to make it private to this container.
+	SELinux string `hcl:"selinux,optional" json:"selinux,omitempty"`
+	// VolumeName is the name of the docker volume, supporting
+	// template variables, e.g. "{id}-world".
+	VolumeName string `hcl:"volume_name,optional" json:"volume_name,omitempty"`
+	// Driver is the docker volume driver, defaults to "local".
+	Driver string `hcl:"driver,optional" json:"driver,omitempty"`
+	// DriverOpts are the options passed to the volume driver.
+	DriverOpts map[string]string `hcl:"driver_opts,optional" json:"driver_opts,omitempty"`
+	// Size limits the size of a tmpfs mount, e.g. "512MB".
+	Size string `hcl:"size,optional" json:"size,omitempty"`
+}
+
+const (
+	// BindTypeBind mounts a host directory.
+	BindTypeBind = "bind"
+	// BindTypeVolume mounts a docker named volume.
+	BindTypeVolume = "volume"
+	// BindTypeTmpfs mounts a temporary filesystem in memory.
+	BindTypeTmpfs = "tmpfs"
+)
+
+// BindType returns the type of the mount, defaulting to BindTypeBind.
+func (b ContainerBind) BindType() string {
+	if b.Type == "" {
+		return BindTypeBind
+	}
+
+	return b.Type
 }
@@ -4,12 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"net"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
-	"code.cloudfoundry.org/bytefmt"
 	"github.com/PanelMc/worker"
+	"github.com/PanelMc/worker/engine"
+	"github.com/PanelMc/worker/node"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
@@ -24,13 +26,24 @@ type dockerContainer struct {
 	ContainerName string
 	ContainerID   string
 	status        worker.Status
+	options       worker.ContainerOptions
 
-	client    *client.Client
-	statsChan <-chan *worker.ContainerStats
+	runtime   *engine.Runtime
+	statsFeed *statsFeed
+	// release releases the resources reserved by the admission
+	release func()
 
 	logger *logrus.Entry
 }
 
+func (c *dockerContainer) Name() string {
+	return c.ContainerName
+}
+
+func (c *dockerContainer) Options() worker.ContainerOptions {
+	return c.options
+}
+
 func (c *dockerContainer) Logger() *logrus.Entry {
 	return c.logger
 }
@@ -41,28 +54,77 @@ func (c *dockerContainer) Status() worker.Status {
 
 var logger = logrus.WithField("context", "container")
 
-// NewDockerContainer creates a new docker container using the given options
-func NewDockerContainer(opts ...worker.ContainerOpts) (worker.Container, error) {
-	options := &worker.ContainerOptions{
+// ServerLabel identifies the containers created by the worker,
+// with the name of the server as value.
+const ServerLabel = "panelmc.worker.server"
+
+// defaultOptions returns the options the given ones are applied on
+func defaultOptions() *worker.ContainerOptions {
+	return &worker.ContainerOptions{
 		ContainerName: "minecraft",
 		Image: worker.ContainerImage{
 			ID: "itzg/minecraft-server",
 		},
 		Memory: worker.ContainerMemory{
 			Limit: "1GB",
-			Swap:  "1GB",
+			Swap:  worker.SwapDisabled,
 		},
 		Binds: make([]worker.ContainerBind, 0),
 		Network: &worker.ContainerNetwork{
 			Binds: make([]worker.ContainerNetworkBind, 0),
 		},
 	}
+}
+
+// ReleaseAdmission releases the resources reserved by the admission of the
+// container, once its server is registered and accounted by the node.
+func (c *dockerContainer) ReleaseAdmission() {
+	c.Lock()
+	release := c.release
+	c.release = nil
+	c.Unlock()
+
+	if release != nil {
+		release()
+	}
+}
+
+// client returns the docker client of the shared runtime.
+func (c *dockerContainer) client() *client.Client {
+	return c.runtime.Client()
+}
+
+// NewDockerContainer creates a new docker container using the given options
+func NewDockerContainer(opts ...worker.ContainerOpts) (worker.Container, error) {
+	return NewDockerContainerContext(context.Background(), opts...)
+}
+
+// NewDockerContainerContext creates a new docker container using the given options,
+// cancelling the creation, including the image pull, when the context is done.
+func NewDockerContainerContext(ctx context.Context, opts ...worker.ContainerOpts) (worker.Container, error) {
+	options := defaultOptions()
 
 	for _, opt := range opts {
 		opt(options)
 	}
 
-	cli, err := client.NewClientWithOpts(client.FromEnv)
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	release, err := node.Admit(options)
+	if err != nil {
+		return nil, err
+	}
+	// On success, the resources stay reserved until the server is registered
+	created := false
+	defer func() {
+		if !created {
+			release()
+		}
+	}()
+
+	runtime, err := engine.Default()
 	if err != nil {
 		return nil, err
 	}
@@ -71,78 +133,186 @@ func NewDockerContainer(opts ...worker.ContainerOpts) (worker.Container, error)
 	container := &dockerContainer{
 		ContainerName: options.ContainerName,
 		status:        worker.StatusStopped,
-		client:        cli,
+		options:       *options,
+		runtime:       runtime,
 		logger:        logger,
+		release:       release,
 	}
 
-	ctx := context.TODO()
-
 	if err := prepare(ctx, container, options); err != nil {
 		return nil, err
 	}
 
-	containerConfig := parseContainerConfig(container, options)
-	containerHostConfig := parseHostConfig(container, options)
+	if err := pinImage(ctx, container, options); err != nil {
+		return nil, err
+	}
+
+	if err := provisionDirs(ctx, container, options); err != nil {
+		return nil, err
+	}
 
-	resContainer, err := cli.ContainerCreate(ctx, &containerConfig, &containerHostConfig, nil, containerConfig.Hostname)
+	id, err := container.create(ctx, options, "")
 	if err != nil {
 		return nil, err
 	}
+	container.ContainerID = id
+	container.options = *options
 
-	container.ContainerID = resContainer.ID
+	if err := worker.SaveSpec(*options); err != nil {
+		container.Logger().Errorf("Failed to save the container spec: %s", err)
+	}
 
+	created = true
 	return container, nil
 }
 
+// create creates the docker container with the given options, connecting
+// it to its networks, and returns its ID. The container is named after the
+// options, unless another name is given.
+func (c *dockerContainer) create(ctx context.Context, opts *worker.ContainerOptions, name string) (string, error) {
+	req, err := c.createRequest(opts)
+	if err != nil {
+		return "", err
+	}
+	if name == "" {
+		name = req.Name
+	}
+
+	// The networks can't be removed until the container is attached
+	networksLock.RLock()
+	defer networksLock.RUnlock()
+
+	if err := ensureNetworks(ctx, c, opts); err != nil {
+		return "", err
+	}
+
+	resContainer, err := c.client().ContainerCreate(ctx, req.Config, req.HostConfig, req.NetworkingConfig, name)
+	if err != nil {
+		return "", err
+	}
+
+	if err := connectNetworks(ctx, c, resContainer.ID, opts); err != nil {
+		return resContainer.ID, err
+	}
+
+	// Keep the flags the container was created with, to tell when a resize changes them
+	if opts.JVM != nil {
+		jvm := *opts.JVM
+		jvm.Applied, _ = jvm.Args(opts.Memory.Limit)
+		opts.JVM = &jvm
+	}
+
+	return resContainer.ID, nil
+}
+
+// createRequest returns the docker create request for the given options.
+func (c *dockerContainer) createRequest(opts *worker.ContainerOptions) (*CreateRequest, error) {
+	containerConfig, err := parseContainerConfig(c, opts)
+	if err != nil {
+		return nil, err
+	}
+	containerHostConfig, err := parseHostConfig(c, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	_, networkingConfig := parseNetworkingConfig(opts)
+
+	return &CreateRequest{
+		Name:             containerConfig.Hostname,
+		Config:           &containerConfig,
+		HostConfig:       &containerHostConfig,
+		NetworkingConfig: networkingConfig,
+	}, nil
+}
+
 func prepare(ctx context.Context, container *dockerContainer, opts *worker.ContainerOptions) error {
 	select {
 	case <-ctx.Done():
-		return nil
+		return ctx.Err()
 	default:
 	}
 
+	if opts.Image.Build != nil {
+		return buildImage(ctx, container, opts.Image)
+	}
+
+	policy := opts.Image.Policy()
+	if policy != worker.PullAlways {
+		present, err := imagePresent(ctx, container, opts.Image)
+		if err != nil {
+			return fmt.Errorf("failed to inspect image '%s': %w", opts.Image.ID, err)
+		}
+
+		if present {
+			container.Logger().Debugf("Image %s present, skipping pull.", opts.Image.ID)
+			return nil
+		}
+
+		if policy == worker.PullNever {
+			return &worker.ImagePullError{
+				Image: opts.Image.ID,
+				Err:   fmt.Errorf("image not present and the pull policy is '%s'", policy),
+			}
+		}
+	}
+
 	container.Logger().Debugln("Checking image for updates...")
-	progress, err := pullImage(ctx, container, opts.Image)
+	return pull(ctx, container, opts.Image)
+}
+
+// pull pulls the image, logging its progress.
+func pull(ctx context.Context, container *dockerContainer, image worker.ContainerImage) error {
+	pull, err := pullImage(ctx, container, image)
 	if err != nil {
-		return fmt.Errorf("image pull error for '%s': %w", opts.Image, err)
+		return pullError(image, err)
 	}
 
-	var p *imagePullEvent
-	var ok bool = true
-	for ok {
+	for {
 		select {
 		case <-ctx.Done():
-			return nil
-		case p, ok = <-progress:
-			if ok {
-				if p.Progress != "" {
-					container.Logger().Debugf("Pulling progress: %s | %s", p.Status, p.Progress)
-				} else {
-					container.Logger().Debugf("Pulling progress: %s", p.Status)
+			return ctx.Err()
+		case p, ok := <-pull.Events():
+			if !ok {
+				if err := pull.Err(); err != nil {
+					return pullError(image, err)
 				}
+				return nil
 			}
+
+			container.Logger().Debugf("Pulling progress: %s", p)
 		}
 	}
+}
 
-	// Return error from last message if present
-	if p != nil && p.Error != "" {
-		return errors.New(p.Error)
+// pullError wraps the error of a pull into a *worker.ImagePullError,
+// unless it was cancelled.
+func pullError(image worker.ContainerImage, err error) error {
+	var pullErr *worker.ImagePullError
+	if errors.As(err, &pullErr) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
 	}
 
-	return nil
+	return &worker.ImagePullError{Image: image.ID, Err: err}
 }
 
-func parseContainerConfig(c *dockerContainer, opts *worker.ContainerOptions) container.Config {
-	portSet, _, err := parsePortSpecs(opts.Network.Binds)
-	if err != nil {
-		c.Logger().Errorf("Error parsing the container ports: %s", err)
-		portSet = make(map[nat.Port]struct{})
+// containerError marks the docker errors of a missing container as worker.ErrNotFound.
+func containerError(c *dockerContainer, err error) error {
+	if client.IsErrNotFound(err) {
+		return &worker.NotFoundError{Server: c.Name(), Err: err}
 	}
 
-	volumes, _ := parseVolumeBinds(c, opts.Binds)
+	return err
+}
+
+func parseContainerConfig(c *dockerContainer, opts *worker.ContainerOptions) (container.Config, error) {
+	portSet, _, err := parsePortSpecs(opts.Network)
+	if err != nil {
+		return container.Config{}, fmt.Errorf("invalid container ports: %w", err)
+	}
 
 	containerConfig := container.Config{
-		Image:        opts.Image.ID,
+		Image:        opts.Image.Ref(),
 		AttachStdin:  true,
 		OpenStdin:    true,
 		AttachStdout: true,
@@ -150,7 +320,9 @@ func parseContainerConfig(c *dockerContainer, opts *worker.ContainerOptions) con
 		Tty:          true,
 		Hostname:     "daemon-" + c.ContainerName,
 		ExposedPorts: portSet,
-		Volumes:      volumes,
+		Labels: map[string]string{
+			ServerLabel: c.ContainerName,
+		},
 		Env: []string{
 			"EULA=TRUE",
 			"PAPER_DOWNLOAD_URL=https://heroslender.com/assets/PaperSpigot-1.8.8.jar",
@@ -160,159 +332,110 @@ func parseContainerConfig(c *dockerContainer, opts *worker.ContainerOptions) con
 		},
 	}
 
-	return containerConfig
+	containerConfig.Env = parseEnv(opts, containerConfig.Env)
+
+	if opts.Security != nil {
+		containerConfig.User = opts.Security.User
+	}
+
+	if opts.JVM != nil {
+		env, cmd, err := opts.JVM.Apply(opts.Memory.Limit, containerConfig.Env)
+		if err != nil {
+			return container.Config{}, err
+		}
+
+		containerConfig.Env = env
+		if cmd != nil {
+			for i, arg := range cmd {
+				cmd[i] = opts.Template(arg)
+			}
+			containerConfig.Cmd = cmd
+		}
+	}
+
+	return containerConfig, nil
 }
 
-func parseHostConfig(c *dockerContainer, opts *worker.ContainerOptions) container.HostConfig {
-	_, portMap, err := parsePortSpecs(opts.Network.Binds)
+func parseHostConfig(c *dockerContainer, opts *worker.ContainerOptions) (container.HostConfig, error) {
+	_, portMap, err := parsePortSpecs(opts.Network)
 	if err != nil {
-		c.Logger().Errorf("Error parsing the container ports: %s", err)
-		portMap = make(map[nat.Port][]nat.PortBinding)
+		return container.HostConfig{}, fmt.Errorf("invalid container ports: %w", err)
 	}
 
-	_, binds := parseVolumeBinds(c, opts.Binds)
-
-	memory, err := bytefmt.ToBytes(opts.Memory.Limit)
+	mounts, binds, err := parseMounts(c, opts)
 	if err != nil {
-		c.Logger().Error("Failed to read server RAM, using default(1 Gigabyte).")
-		memory = 1073741824 // 1GB Default
+		return container.HostConfig{}, err
 	}
-	swap, err := bytefmt.ToBytes(opts.Memory.Swap)
+
+	resources, err := parseResources(c, opts)
 	if err != nil {
-		c.Logger().Error("Failed to read server Swap, using default(1 Gigabyte).")
-		swap = 1073741824 // 1GB Default
+		return container.HostConfig{}, err
 	}
 
+	networkMode, _ := parseNetworkingConfig(opts)
+
 	containerHostConfig := container.HostConfig{
-		Resources: container.Resources{
-			Memory:     int64(memory),
-			MemorySwap: int64(swap),
-		},
+		Resources:    resources,
+		Mounts:       mounts,
 		Binds:        binds,
 		PortBindings: portMap,
+		NetworkMode:  container.NetworkMode(networkMode),
+	}
+
+	if err := parseSecurity(opts, &containerHostConfig); err != nil {
+		return container.HostConfig{}, err
 	}
 
-	return containerHostConfig
+	return containerHostConfig, nil
 }
 
-func parsePortSpecs(portBinds []worker.ContainerNetworkBind) (nat.PortSet, nat.PortMap, error) {
+func parsePortSpecs(network *worker.ContainerNetwork) (nat.PortSet, nat.PortMap, error) {
 	var (
 		exposedPorts = make(nat.PortSet)
 		bindings     = make(nat.PortMap)
 	)
 
-	for _, rawPort := range portBinds {
-		portMappings, err := parsePortSpec(rawPort)
+	portBindings, err := network.PortBindings()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, binding := range portBindings {
+		port, err := nat.NewPort(binding.Proto, strconv.Itoa(binding.ContainerPort))
 		if err != nil {
 			return nil, nil, err
 		}
 
-		for _, portMapping := range portMappings {
-			port := portMapping.Port
-			if _, exists := exposedPorts[port]; !exists {
-				exposedPorts[port] = struct{}{}
-			}
-			bSlice, exists := bindings[port]
-			if !exists {
-				bSlice = []nat.PortBinding{}
-			}
-			bindings[port] = append(bSlice, portMapping.Binding)
-		}
+		exposedPorts[port] = struct{}{}
+		bindings[port] = append(bindings[port], nat.PortBinding{
+			HostIP:   binding.HostIP,
+			HostPort: strconv.Itoa(binding.HostPort),
+		})
 	}
 
-	logrus.Infof("Ports: %#v", bindings)
 	return exposedPorts, bindings, nil
 }
 
-func parsePortSpec(portBind worker.ContainerNetworkBind) ([]nat.PortMapping, error) {
-	hostIP, hostPort := splitIPPort(portBind.Addr)
-	hostIP, err := parseIP(hostIP)
-	if err != nil {
-		return nil, err
-	}
-
-	if hostPort == "" {
-		return nil, errors.New("undefined port for bind")
-	}
-
-	cPort := portBind.Private
-	if cPort == "" {
-		cPort = hostPort
-	}
-
-	cProto := portBind.Proto
-	if cProto == "" {
-		cProto = "tcp"
-	}
-
-	port, err := nat.NewPort(cProto, cPort)
-	if err != nil {
-		return nil, err
-	}
-
-	// TODO port ranges
-	return []nat.PortMapping{
-		{
-			Port: port,
-			Binding: nat.PortBinding{
-				HostIP:   hostIP,
-				HostPort: hostPort,
-			},
-		},
-	}, nil
-}
-
-func parseIP(rawIP string) (string, error) {
-	// Strip [] from IPV6 addresses
-	ip, _, err := net.SplitHostPort(rawIP + ":")
-	if err != nil {
-		return "", fmt.Errorf("invalid ip address %v: %s", rawIP, err)
-	}
-	if ip != "" && net.ParseIP(ip) == nil {
-		return "", fmt.Errorf("invalid ip address: %s", ip)
+// parseEnv overrides the default environment with the one in the options,
+// replacing the template variables in the values.
+func parseEnv(opts *worker.ContainerOptions, defaults []string) []string {
+	env := make([]string, 0, len(defaults)+len(opts.Env))
+	for _, e := range defaults {
+		key := strings.SplitN(e, "=", 2)[0]
+		if _, ok := opts.Env[key]; !ok {
+			env = append(env, e)
+		}
 	}
 
-	return ip, nil
-}
-
-func splitIPPort(rawIP string) (string, string) {
-	parts := strings.Split(rawIP, ":")
-	length := len(parts)
-
-	switch length {
-	case 1:
-		return "", parts[0]
-	case 2:
-		return parts[0], parts[1]
-	default:
-		// IPV6
-		return strings.Join(parts[:length-1], ":"), parts[length-1]
+	keys := make([]string, 0, len(opts.Env))
+	for key := range opts.Env {
+		keys = append(keys, key)
 	}
-}
+	sort.Strings(keys)
 
-func parseVolumeBinds(c *dockerContainer, binds []worker.ContainerBind) (map[string]struct{}, []string) {
-	var (
-		volumes  = make(map[string]struct{})
-		bindings = make([]string, 0)
-	)
-
-	for _, bind := range binds {
-		bind.HostDir = strings.ReplaceAll(bind.HostDir, "{id}", c.ContainerName)
-		volumes[bind.Volume] = struct{}{}
-		binding := fmt.Sprintf("%s:%s", bind.HostDir, bind.Volume)
-
-		var contains bool
-		for _, b := range bindings {
-			if b == binding {
-				contains = true
-				break
-			}
-		}
-
-		if !contains {
-			bindings = append(bindings, binding)
-		}
+	for _, key := range keys {
+		env = append(env, key+"="+opts.Template(opts.Env[key]))
 	}
 
-	return volumes, bindings
+	return env
 }
@@ -0,0 +1,365 @@
+package container
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/PanelMc/worker"
+	docker "github.com/docker/docker/api/types"
+)
+
+// BuildHashLabel holds the hash of the build context an image was built
+// from, marking the images built by the worker
+const BuildHashLabel = "panelmc.worker.build-hash"
+
+// imageBuilds serializes the builds of the same image tag
+var imageBuilds struct {
+	sync.Mutex
+	tags map[string]*sync.Mutex
+	// active counts the builds in progress, or waiting, of each tag
+	active map[string]int
+}
+
+func buildLock(tag string) *sync.Mutex {
+	imageBuilds.Lock()
+	defer imageBuilds.Unlock()
+
+	if imageBuilds.tags == nil {
+		imageBuilds.tags = make(map[string]*sync.Mutex)
+	}
+
+	lock, ok := imageBuilds.tags[tag]
+	if !ok {
+		lock = &sync.Mutex{}
+		imageBuilds.tags[tag] = lock
+	}
+
+	return lock
+}
+
+// trackBuild marks the build of the tag as in progress,
+// until the returned function is called.
+func trackBuild(tag string) (done func()) {
+	imageBuilds.Lock()
+	defer imageBuilds.Unlock()
+
+	if imageBuilds.active == nil {
+		imageBuilds.active = make(map[string]int)
+	}
+	imageBuilds.active[tag]++
+
+	return func() {
+		imageBuilds.Lock()
+		defer imageBuilds.Unlock()
+
+		if imageBuilds.active[tag]--; imageBuilds.active[tag] == 0 {
+			delete(imageBuilds.active, tag)
+		}
+	}
+}
+
+// buildImage builds the image from its build context, unless the image
+// was already built from the same context, streaming the build logs
+// into the container logger.
+func buildImage(ctx context.Context, container *dockerContainer, image worker.ContainerImage) error {
+	build := *image.Build
+	defer trackBuild(image.ID)()
+
+	lock := buildLock(image.ID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	hash, err := contextHash(build)
+	if err != nil {
+		return fmt.Errorf("failed to read the build context of '%s': %w", image.ID, err)
+	}
+
+	inspect, _, err := container.client().ImageInspectWithRaw(ctx, image.ID)
+	if err == nil && inspect.Config != nil && inspect.Config.Labels[BuildHashLabel] == hash {
+		container.Logger().Debugf("Image %s is up to date with its build context.", image.ID)
+		return nil
+	}
+
+	container.Logger().Infof("Building image %s from %s...", image.ID, build.Context)
+
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(writeContext(w, build.Context))
+	}()
+	defer r.Close()
+
+	args := make(map[string]*string, len(build.Args))
+	for name, value := range build.Args {
+		value := value
+		args[name] = &value
+	}
+
+	auths, err := buildAuths(build)
+	if err != nil {
+		return fmt.Errorf("failed to read the base images of '%s': %w", image.ID, err)
+	}
+
+	res, err := container.client().ImageBuild(ctx, r, docker.ImageBuildOptions{
+		Tags:        []string{image.ID},
+		Dockerfile:  filepath.ToSlash(filepath.Clean(build.DockerfilePath())),
+		BuildArgs:   args,
+		AuthConfigs: auths,
+		Labels:      map[string]string{BuildHashLabel: hash},
+		Remove:      true,
+		ForceRemove: true,
+	})
+	if err != nil {
+		return fmt.Errorf("image build error for '%s': %w", image.ID, err)
+	}
+	defer res.Body.Close()
+
+	if err := readBuildResponse(container, res.Body); err != nil {
+		return fmt.Errorf("image build error for '%s': %w", image.ID, err)
+	}
+
+	container.Logger().Infof("Image %s built!", image.ID)
+	return nil
+}
+
+// buildAuths returns the credentials of the registries of the base images
+// in the Dockerfile, keyed by registry as expected by the daemon.
+func buildAuths(build worker.ContainerImageBuild) (map[string]docker.AuthConfig, error) {
+	images, err := baseImages(filepath.Join(build.Context, build.DockerfilePath()), build.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	auths := make(map[string]docker.AuthConfig)
+	for _, image := range images {
+		auth, ok, err := worker.GetRegistryAuth(image)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		host, err := worker.ImageRegistry(image)
+		if err != nil {
+			return nil, err
+		}
+		key := host
+		if host == "docker.io" {
+			// The daemon looks up the Docker Hub credentials by its index address
+			key = "https://index.docker.io/v1/"
+		}
+
+		auths[key] = docker.AuthConfig{
+			Username:      auth.Username,
+			Password:      auth.Password,
+			IdentityToken: auth.IdentityToken,
+			ServerAddress: key,
+		}
+	}
+
+	return auths, nil
+}
+
+// baseImages returns the images of the FROM instructions of the Dockerfile,
+// expanding the build arguments. The build stages, "scratch", and the images
+// with unknown arguments are skipped.
+func baseImages(dockerfile string, args map[string]string) ([]string, error) {
+	content, err := ioutil.ReadFile(dockerfile)
+	if err != nil {
+		return nil, err
+	}
+
+	stages := make(map[string]bool)
+	images := make([]string, 0)
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.EqualFold(fields[0], "FROM") {
+			continue
+		}
+
+		fields = fields[1:]
+		for len(fields) > 0 && strings.HasPrefix(fields[0], "--") {
+			fields = fields[1:]
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) >= 3 && strings.EqualFold(fields[1], "AS") {
+			stages[strings.ToLower(fields[2])] = true
+		}
+
+		known := true
+		image := os.Expand(fields[0], func(name string) string {
+			value, ok := args[name]
+			known = known && ok
+			return value
+		})
+		if !known || strings.EqualFold(image, "scratch") || stages[strings.ToLower(image)] {
+			continue
+		}
+
+		images = append(images, image)
+	}
+
+	return images, nil
+}
+
+// readBuildResponse streams the build output into the container logger,
+// returning the build error if any.
+func readBuildResponse(container *dockerContainer, r io.Reader) error {
+	d := json.NewDecoder(r)
+	for {
+		var message struct {
+			Stream string `json:"stream"`
+			Status string `json:"status"`
+			Error  string `json:"error"`
+		}
+		if err := d.Decode(&message); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		if message.Error != "" {
+			return errors.New(message.Error)
+		}
+
+		for _, line := range strings.Split(strings.TrimSpace(message.Stream+message.Status), "\n") {
+			if line != "" {
+				container.Logger().Info(line)
+			}
+		}
+	}
+}
+
+// contextFiles returns the relative paths of the files in the build context, sorted.
+func contextFiles(dir string) ([]string, error) {
+	files := make([]string, 0)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		files = append(files, rel)
+		return nil
+	})
+	sort.Strings(files)
+
+	return files, err
+}
+
+// contextHash returns the hash of the build context files, their modes,
+// and the build options, changing whenever the image needs a rebuild.
+func contextHash(build worker.ContainerImageBuild) (string, error) {
+	files, err := contextFiles(build.Context)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "dockerfile %s\n", build.DockerfilePath())
+
+	args := make([]string, 0, len(build.Args))
+	for name, value := range build.Args {
+		args = append(args, name+"="+value)
+	}
+	sort.Strings(args)
+	for _, arg := range args {
+		fmt.Fprintf(hash, "arg %s\n", arg)
+	}
+
+	for _, file := range files {
+		path := filepath.Join(build.Context, file)
+		info, err := os.Lstat(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(hash, "file %s %s\n", filepath.ToSlash(file), info.Mode())
+
+		switch {
+		case info.Mode().IsRegular():
+			f, err := os.Open(path)
+			if err != nil {
+				return "", err
+			}
+			_, err = io.Copy(hash, f)
+			f.Close()
+			if err != nil {
+				return "", err
+			}
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(hash, "link %s\n", target)
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// writeContext writes the build context directory as a tar archive.
+func writeContext(w io.Writer, dir string) error {
+	files, err := contextFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	for _, file := range files {
+		path := filepath.Join(dir, file)
+		info, err := os.Lstat(path)
+		if err != nil {
+			return err
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(file)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(tw, f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tw.Close()
+}
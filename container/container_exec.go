@@ -1,6 +1,12 @@
 package container
 
+import "context"
+
 func (c *dockerContainer) Exec(cmd string) error {
+	return c.ExecContext(context.Background(), cmd)
+}
+
+func (c *dockerContainer) ExecContext(ctx context.Context, cmd string) error {
 	// TODO - requires to be attached to the container
-	return nil
-}
\ No newline at end of file
+	return ctx.Err()
+}
@@ -2,80 +2,395 @@ package container
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"io"
 	"sync"
 
+	"code.cloudfoundry.org/bytefmt"
 	"github.com/PanelMc/worker"
 	docker "github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
 )
 
 type imagePullEvent struct {
+	// ID of the layer the event refers to, if any
+	ID             string `json:"id"`
 	Status         string `json:"status"`
 	Error          string `json:"error"`
 	Progress       string `json:"progress"`
 	ProgressDetail struct {
-		Current int `json:"current"`
-		Total   int `json:"total"`
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
 	} `json:"progressDetail"`
 }
 
+// imagePullProgress is an event of an image pull, along with
+// the overall progress of the pull.
+type imagePullProgress struct {
+	Event *imagePullEvent `json:"event,omitempty"`
+	// Current is the number of bytes downloaded, of all layers
+	Current int64 `json:"current"`
+	// Total is the number of bytes to download, of the layers with a known size
+	Total      int64   `json:"total"`
+	Percentage float64 `json:"percentage"`
+	Layers     int     `json:"layers"`
+	Completed  int     `json:"completed"`
+}
+
+func (p *imagePullProgress) String() string {
+	status := ""
+	if p.Event != nil {
+		status = p.Event.Status
+		if p.Event.ID != "" {
+			status = p.Event.ID + ": " + status
+		}
+	}
+
+	return fmt.Sprintf("%.1f%% (%s/%s, %d/%d layers) | %s", p.Percentage,
+		bytefmt.ByteSize(uint64(p.Current)), bytefmt.ByteSize(uint64(p.Total)), p.Completed, p.Layers, status)
+}
+
+// imagePulls coordinates the image pulls, so concurrent pulls
+// of the same image share the same docker pull.
 var imagePulls struct {
 	sync.Mutex
-	pending map[string]chan *imagePullEvent
+	pending map[string]*imagePull
+}
+
+// imagePull is an image pull in progress, broadcasting its events to all the waiters.
+type imagePull struct {
+	sync.Mutex
+	image   string
+	cancel  context.CancelFunc
+	waiters map[*imagePullWaiter]bool
+	layers  map[string]*layerProgress
+	// cancelled is set when all the waiters left before the pull finished
+	cancelled bool
+	done      bool
+	err       error
+}
+
+type layerProgress struct {
+	current, total int64
+	completed      bool
+}
+
+// imagePullWaiter receives the events of an image pull.
+type imagePullWaiter struct {
+	pull   *imagePull
+	ctx    context.Context
+	events chan *imagePullProgress
+	// closed when the waiter leaves the pull
+	left chan struct{}
+}
+
+// Events returns the pull events, closed when the pull finishes.
+// No more events are delivered once the waiter context is done.
+func (w *imagePullWaiter) Events() <-chan *imagePullProgress {
+	return w.events
+}
+
+// Err returns the error of the pull, or of the waiter context.
+// Only valid after the events channel is closed or the context is done.
+func (w *imagePullWaiter) Err() error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+
+	w.pull.Lock()
+	defer w.pull.Unlock()
+
+	return w.pull.err
+}
+
+// PendingImages returns the images being pulled or built.
+func PendingImages() []string {
+	images := make([]string, 0)
+
+	imagePulls.Lock()
+	for image := range imagePulls.pending {
+		images = append(images, image)
+	}
+	imagePulls.Unlock()
+
+	imageBuilds.Lock()
+	for image := range imageBuilds.active {
+		images = append(images, image)
+	}
+	imageBuilds.Unlock()
+
+	return images
 }
 
-func pullImage(ctx context.Context, container *dockerContainer, image worker.ContainerImage) (<-chan *imagePullEvent, error) {
+// pullImage joins the pull of the given image, starting it if
+// there isn't one in progress. The pull is cancelled when
+// the context of all its waiters is done.
+func pullImage(ctx context.Context, container *dockerContainer, image worker.ContainerImage) (*imagePullWaiter, error) {
 	imagePulls.Lock()
-	defer imagePulls.Unlock()
+	var w *imagePullWaiter
+	pull := imagePulls.pending[image.ID]
+	if pull != nil {
+		// Fails if the last waiter left, cancelling the pull
+		w = pull.join(ctx)
+	}
+	started := w == nil
+	var pullCtx context.Context
+	if started {
+		if imagePulls.pending == nil {
+			imagePulls.pending = make(map[string]*imagePull)
+		}
 
-	ch := imagePulls.pending[image.ID]
-	if ch != nil {
-		return ch, nil
+		var cancel context.CancelFunc
+		pullCtx, cancel = context.WithCancel(context.Background())
+		pull = &imagePull{
+			image:   image.ID,
+			cancel:  cancel,
+			waiters: make(map[*imagePullWaiter]bool),
+			layers:  make(map[string]*layerProgress),
+		}
+		// Registered before the request, so concurrent pulls join this one
+		imagePulls.pending[image.ID] = pull
+		w = pull.join(ctx)
 	}
+	imagePulls.Unlock()
 
-	if imagePulls.pending == nil {
-		imagePulls.pending = make(map[string]chan *imagePullEvent)
+	if !started {
+		container.Logger().Infof("Waiting for the pull of image %s in progress...", image.ID)
+		return w, nil
 	}
-	ch = make(chan *imagePullEvent)
-	imagePulls.pending[image.ID] = ch
 
-	err := execImagePull(ctx, ch, container, image)
+	r, err := execImagePull(pullCtx, container, image)
+	if err != nil {
+		pull.finish(container, err)
+		return nil, err
+	}
 
-	return ch, err
+	go pull.run(container, r)
+	return w, nil
 }
 
-func execImagePull(ctx context.Context, ch chan *imagePullEvent, container *dockerContainer, image worker.ContainerImage) error {
-	container.Logger().Infof("Pulling image %s...", image)
-	r, err := container.client.ImagePull(ctx, image.ID, docker.ImagePullOptions{})
+func execImagePull(ctx context.Context, container *dockerContainer, image worker.ContainerImage) (io.ReadCloser, error) {
+	container.Logger().Infof("Pulling image %s...", image.ID)
+	auth, err := registryAuth(image)
 	if err != nil {
-		close(ch)
-		return err
+		return nil, err
+	}
+
+	return container.client().ImagePull(ctx, image.ID, docker.ImagePullOptions{RegistryAuth: auth})
+}
+
+// join adds a waiter to the pull, or returns nil if the pull was cancelled.
+func (p *imagePull) join(ctx context.Context) *imagePullWaiter {
+	w := &imagePullWaiter{
+		pull:   p,
+		ctx:    ctx,
+		events: make(chan *imagePullProgress, 16),
+		left:   make(chan struct{}),
+	}
+
+	p.Lock()
+	if p.cancelled {
+		p.Unlock()
+		return nil
+	}
+	p.waiters[w] = true
+	if len(p.layers) > 0 {
+		// Let late waiters know the current progress
+		w.events <- p.progress(nil)
 	}
+	p.Unlock()
 
 	go func() {
-		defer func() {
-			r.Close()
+		select {
+		case <-ctx.Done():
+			p.leave(w)
+		case <-w.left:
+		}
+	}()
 
-			imagePulls.Lock()
-			delete(imagePulls.pending, image.ID)
-			imagePulls.Unlock()
+	return w
+}
+
+// leave removes the waiter, cancelling the pull if it was the last one.
+func (p *imagePull) leave(w *imagePullWaiter) {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.waiters[w] {
+		return
+	}
+	delete(p.waiters, w)
+	close(w.left)
+
+	if len(p.waiters) == 0 && !p.done {
+		p.cancelled = true
+		p.cancel()
+	}
+}
 
-			close(ch)
-		}()
+func (p *imagePull) run(container *dockerContainer, r io.ReadCloser) {
+	defer r.Close()
 
-		d := json.NewDecoder(r)
-		var event *imagePullEvent
-		for {
-			if err := d.Decode(&event); err != nil && err == io.EOF {
-				break
+	var err error
+	d := json.NewDecoder(r)
+	for {
+		var event imagePullEvent
+		if decodeErr := d.Decode(&event); decodeErr != nil {
+			if decodeErr != io.EOF {
+				err = decodeErr
 			}
+			break
+		}
 
-			ch <- event
+		if event.Error != "" {
+			err = &worker.ImagePullError{Image: p.image, Message: event.Error}
 		}
 
-		container.Logger().Infof("Image %s pulled!", image)
-	}()
+		p.broadcast(&event)
+	}
+
+	p.finish(container, err)
+}
+
+// finish removes the pull from the pending ones, and closes its waiters.
+func (p *imagePull) finish(container *dockerContainer, err error) {
+	defer p.cancel()
+
+	imagePulls.Lock()
+	// A new pull may have replaced this one if it was cancelled
+	if imagePulls.pending[p.image] == p {
+		delete(imagePulls.pending, p.image)
+	}
+	imagePulls.Unlock()
+
+	p.Lock()
+	p.done = true
+	p.err = err
+	for w := range p.waiters {
+		delete(p.waiters, w)
+		close(w.left)
+		close(w.events)
+	}
+	p.Unlock()
+
+	if err != nil {
+		container.Logger().Errorf("Failed to pull image %s: %s", p.image, err)
+		return
+	}
+	container.Logger().Infof("Image %s pulled!", p.image)
+}
+
+// broadcast sends the event to all the waiters, blocking
+// until each of them receives it or leaves.
+func (p *imagePull) broadcast(event *imagePullEvent) {
+	p.Lock()
+	p.update(event)
+	progress := p.progress(event)
+	waiters := make([]*imagePullWaiter, 0, len(p.waiters))
+	for w := range p.waiters {
+		waiters = append(waiters, w)
+	}
+	p.Unlock()
+
+	for _, w := range waiters {
+		select {
+		case w.events <- progress:
+		case <-w.left:
+		}
+	}
+}
+
+// update updates the layer progress with the given event.
+func (p *imagePull) update(event *imagePullEvent) {
+	if event.ID == "" {
+		return
+	}
+
+	layer, ok := p.layers[event.ID]
+	switch event.Status {
+	case "Pulling fs layer", "Waiting", "Downloading", "Verifying Checksum", "Download complete",
+		"Extracting", "Pull complete", "Already exists":
+	default:
+		// Not a layer event, e.g. the tag being pulled
+		return
+	}
+	if !ok {
+		layer = &layerProgress{}
+		p.layers[event.ID] = layer
+	}
+
+	switch event.Status {
+	case "Downloading":
+		layer.current = event.ProgressDetail.Current
+		if event.ProgressDetail.Total > 0 {
+			layer.total = event.ProgressDetail.Total
+		}
+	case "Verifying Checksum", "Download complete", "Extracting":
+		layer.current = layer.total
+	case "Pull complete", "Already exists":
+		layer.current = layer.total
+		layer.completed = true
+	}
+}
+
+// progress returns the overall progress of the pull.
+func (p *imagePull) progress(event *imagePullEvent) *imagePullProgress {
+	progress := &imagePullProgress{
+		Event:  event,
+		Layers: len(p.layers),
+	}
+
+	for _, layer := range p.layers {
+		progress.Current += layer.current
+		progress.Total += layer.total
+		if layer.completed {
+			progress.Completed++
+		}
+	}
+
+	if progress.Total > 0 {
+		progress.Percentage = float64(progress.Current) / float64(progress.Total) * 100
+	}
+
+	return progress
+}
+
+// imagePresent returns whether the image is present on the node.
+func imagePresent(ctx context.Context, container *dockerContainer, image worker.ContainerImage) (bool, error) {
+	if _, _, err := container.client().ImageInspectWithRaw(ctx, image.ID); err != nil {
+		if client.IsErrNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// registryAuth returns the encoded credentials of the image registry,
+// or an empty string if there are none.
+func registryAuth(image worker.ContainerImage) (string, error) {
+	auth, ok, err := worker.GetRegistryAuth(image.ID)
+	if err != nil || !ok {
+		return "", err
+	}
+
+	host, err := worker.ImageRegistry(image.ID)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := json.Marshal(docker.AuthConfig{
+		Username:      auth.Username,
+		Password:      auth.Password,
+		IdentityToken: auth.IdentityToken,
+		ServerAddress: host,
+	})
+	if err != nil {
+		return "", err
+	}
 
-	return nil
+	return base64.URLEncoding.EncodeToString(b), nil
 }
@@ -0,0 +1,229 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PanelMc/worker"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+)
+
+// pinImage resolves the image tag into the image ID, and its registry digest.
+func pinImage(ctx context.Context, c *dockerContainer, opts *worker.ContainerOptions) error {
+	inspect, _, err := c.client().ImageInspectWithRaw(ctx, opts.Image.ID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect image '%s': %w", opts.Image.ID, err)
+	}
+
+	opts.Image.Pinned = inspect.ID
+	opts.Image.Digest = repoDigest(opts.Image.ID, inspect.RepoDigests)
+	c.Logger().Debugf("Pinned image %s to %s", opts.Image.ID, opts.Image.Pinned)
+
+	return nil
+}
+
+// repoDigest returns the digest of the image repository, from the repo digests of the image.
+func repoDigest(image string, digests []string) string {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return ""
+	}
+
+	for _, digest := range digests {
+		d, err := reference.ParseNormalizedNamed(digest)
+		if err != nil {
+			continue
+		}
+
+		if d.Name() == named.Name() {
+			return digest
+		}
+	}
+
+	return ""
+}
+
+func (c *dockerContainer) CheckImageUpdate() (worker.ImageStatus, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.checkImageUpdate(context.Background())
+}
+
+func (c *dockerContainer) checkImageUpdate(ctx context.Context) (worker.ImageStatus, error) {
+	image := c.options.Image
+	status := worker.ImageStatus{
+		Image:     image.ID,
+		Pinned:    image.Pinned,
+		Digest:    image.Digest,
+		CheckedAt: time.Now(),
+	}
+
+	inspect, _, err := c.client().ImageInspectWithRaw(ctx, image.ID)
+	if err != nil {
+		return status, fmt.Errorf("failed to inspect image '%s': %w", image.ID, err)
+	}
+
+	status.Latest = inspect.ID
+	status.UpdateAvailable = image.Pinned != "" && image.Pinned != inspect.ID
+
+	return status, nil
+}
+
+func (c *dockerContainer) UpdateImage() (worker.ImageStatus, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	ctx := context.Background()
+	if c.options.Image.Build != nil {
+		if err := buildImage(ctx, c, c.options.Image); err != nil {
+			return worker.ImageStatus{}, err
+		}
+	} else if c.options.Image.Policy() != worker.PullNever {
+		if err := pull(ctx, c, c.options.Image); err != nil {
+			return worker.ImageStatus{}, err
+		}
+	}
+
+	status, err := c.checkImageUpdate(ctx)
+	if err != nil {
+		return status, err
+	}
+	if !status.UpdateAvailable {
+		c.Logger().Infof("Image %s is up to date.", status.Image)
+		return status, nil
+	}
+
+	c.Logger().Infof("Updating image %s from %s to %s...", status.Image, shortID(status.Pinned), shortID(status.Latest))
+	options := c.options
+	if err := pinImage(ctx, c, &options); err != nil {
+		return status, err
+	}
+
+	if err := c.recreate(ctx, &options); err != nil {
+		return status, err
+	}
+
+	status.Pinned, status.Digest = options.Image.Pinned, options.Image.Digest
+	status.UpdateAvailable = false
+	c.Logger().Infof("Image %s updated.", status.Image)
+
+	return status, nil
+}
+
+// recreate replaces the docker container with a new one created with
+// the given options, restoring the previous power state. The stats
+// stream is reopened once the lock, held by the caller, is released.
+//
+// The previous container is renamed aside while the new one is created,
+// and restored if the creation fails.
+func (c *dockerContainer) recreate(ctx context.Context, opts *worker.ContainerOptions) error {
+	inspect, err := c.client().ContainerInspect(ctx, c.ContainerID)
+	if err != nil {
+		return err
+	}
+	running := inspect.State != nil && inspect.State.Running
+
+	if running {
+		c.Logger().Debug("Stopping the container to recreate it...")
+		timeout := time.Duration(time.Second * 15)
+		if err := c.client().ContainerStop(ctx, c.ContainerID, &timeout); err != nil {
+			return fmt.Errorf("failed to stop the container: %w", err)
+		}
+		c.status = worker.StatusStopped
+	}
+
+	// The container may still be aside, if a previous recreation failed to restore it
+	current := strings.TrimPrefix(inspect.Name, "/")
+	name := strings.TrimSuffix(current, recreateSuffix)
+	aside := name + recreateSuffix
+
+	if current != aside {
+		// Left by a recreation interrupted before removing the previous container
+		err = c.client().ContainerRemove(ctx, aside, types.ContainerRemoveOptions{Force: true})
+		if err != nil && !client.IsErrNotFound(err) {
+			c.Logger().Errorf("Failed to remove the stale container %s: %s", aside, err)
+		}
+
+		if err := c.client().ContainerRename(ctx, c.ContainerID, aside); err != nil {
+			c.restore(ctx, "", running)
+			return fmt.Errorf("failed to rename the container: %w", err)
+		}
+	}
+
+	id, err := c.create(ctx, opts, name)
+	if err != nil {
+		if id != "" {
+			c.removeQuietly(ctx, id)
+		}
+		c.restore(ctx, name, running)
+		return fmt.Errorf("failed to create the container: %w", err)
+	}
+
+	if err := c.client().ContainerRemove(ctx, c.ContainerID, types.ContainerRemoveOptions{}); err != nil {
+		// Removed by the next recreation
+		c.Logger().Errorf("Failed to remove the previous container %s: %s", shortID(c.ContainerID), err)
+	}
+	c.ContainerID = id
+	c.options = *opts
+
+	if err := worker.SaveSpec(*opts); err != nil {
+		c.Logger().Errorf("Failed to save the container spec: %s", err)
+	}
+
+	if running {
+		if err := c.client().ContainerStart(ctx, c.ContainerID, types.ContainerStartOptions{}); err != nil {
+			return fmt.Errorf("container recreated, but failed to start it: %w", err)
+		}
+		c.status = worker.StatusRunning
+	}
+
+	return nil
+}
+
+// recreateSuffix is appended to the name of the previous container,
+// while the new one is created.
+const recreateSuffix = "-recreating"
+
+// restore restores the previous container after a failed recreation,
+// renaming it back to the given name, if any, and restarting it if it
+// was running.
+func (c *dockerContainer) restore(ctx context.Context, name string, running bool) {
+	if name != "" {
+		if err := c.client().ContainerRename(ctx, c.ContainerID, name); err != nil {
+			// Renamed back by the next recreation
+			c.Logger().Errorf("Failed to rename the previous container back to %s: %s", name, err)
+		}
+	}
+
+	if !running {
+		return
+	}
+
+	if err := c.client().ContainerStart(ctx, c.ContainerID, types.ContainerStartOptions{}); err != nil {
+		c.Logger().Errorf("Failed to restart the previous container: %s", err)
+		return
+	}
+	c.status = worker.StatusRunning
+}
+
+// removeQuietly removes the given container, logging the failures.
+func (c *dockerContainer) removeQuietly(ctx context.Context, id string) {
+	if err := c.client().ContainerRemove(ctx, id, types.ContainerRemoveOptions{Force: true}); err != nil {
+		c.Logger().Errorf("Failed to remove the container %s: %s", shortID(id), err)
+	}
+}
+
+// shortID returns the short form of an image ID, as shown by docker.
+func shortID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+
+	return id
+}
@@ -0,0 +1,128 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"code.cloudfoundry.org/bytefmt"
+	"github.com/PanelMc/worker"
+	"github.com/PanelMc/worker/io"
+	"github.com/PanelMc/worker/node"
+	"github.com/docker/docker/api/types/mount"
+)
+
+// volumeLabel identifies the volumes created by the worker
+const volumeLabel = "panelmc.worker.volume"
+
+// provisionDirs creates the missing host directories of the binds, with the
+// configured permissions and owner, as docker would create them owned by root.
+// Existing directories, e.g. shared by several servers, are left unchanged.
+// With user namespace remapping, the owner is mapped to the host ids, and
+// the directories default to be owned by the remapped root.
+func provisionDirs(ctx context.Context, c *dockerContainer, opts *worker.ContainerOptions) error {
+	uid, gid := -1, -1
+	if opts.Owner != nil {
+		uid, gid = opts.Owner.UID, opts.Owner.GID
+	}
+
+	if opts.Security.Remapped() {
+		remap, err := node.UsernsRemap(ctx, c.client())
+		if err != nil {
+			return fmt.Errorf("failed to read the user namespace remapping: %w", err)
+		}
+
+		if remap != nil {
+			if opts.Owner == nil {
+				uid, gid = 0, 0
+			}
+			uid, gid = remap.Map(uid, gid)
+		}
+	}
+
+	for _, bind := range opts.Binds {
+		if bind.BindType() != worker.BindTypeBind {
+			continue
+		}
+
+		dir := opts.Template(bind.HostDir)
+		c.Logger().Debugf("Provisioning directory %s...", dir)
+		if err := io.ProvisionDir(dir, uid, gid); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseMounts maps the binds into docker mounts. Binds with SELinux relabeling
+// are returned in the binds string format, as mounts don't support it.
+func parseMounts(c *dockerContainer, opts *worker.ContainerOptions) ([]mount.Mount, []string, error) {
+	var (
+		mounts = make([]mount.Mount, 0)
+		binds  = make([]string, 0)
+	)
+
+	for _, bind := range opts.Binds {
+		m := mount.Mount{
+			Type:     mount.Type(bind.BindType()),
+			Target:   bind.Volume,
+			ReadOnly: bind.Mode == "ro",
+		}
+
+		switch bind.BindType() {
+		case worker.BindTypeBind:
+			m.Source = opts.Template(bind.HostDir)
+
+			if bind.SELinux != "" {
+				options := []string{bind.SELinux}
+				if m.ReadOnly {
+					options = append(options, "ro")
+				}
+				if bind.Propagation != "" {
+					options = append(options, bind.Propagation)
+				}
+
+				binds = append(binds, fmt.Sprintf("%s:%s:%s", m.Source, m.Target, strings.Join(options, ",")))
+				continue
+			}
+
+			if bind.Propagation != "" {
+				m.BindOptions = &mount.BindOptions{
+					Propagation: mount.Propagation(bind.Propagation),
+				}
+			}
+		case worker.BindTypeVolume:
+			m.Source = opts.Template(bind.VolumeName)
+			m.VolumeOptions = &mount.VolumeOptions{
+				Labels: map[string]string{
+					volumeLabel: "true",
+				},
+			}
+
+			if bind.Driver != "" || len(bind.DriverOpts) > 0 {
+				m.VolumeOptions.DriverConfig = &mount.Driver{
+					Name:    bind.Driver,
+					Options: bind.DriverOpts,
+				}
+			}
+		case worker.BindTypeTmpfs:
+			m.TmpfsOptions = &mount.TmpfsOptions{}
+
+			if bind.Size != "" {
+				size, err := bytefmt.ToBytes(bind.Size)
+				if err != nil {
+					return nil, nil, fmt.Errorf("invalid tmpfs size '%s': %w", bind.Size, err)
+				}
+
+				m.TmpfsOptions.SizeBytes = int64(size)
+			}
+		default:
+			return nil, nil, fmt.Errorf("invalid bind type '%s'", bind.Type)
+		}
+
+		mounts = append(mounts, m)
+	}
+
+	return mounts, binds, nil
+}
@@ -0,0 +1,159 @@
+package container
+
+import (
+	"context"
+	"sync"
+
+	"github.com/PanelMc/worker"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+)
+
+// networkLabel identifies the networks created by the worker
+const networkLabel = "panelmc.worker.network"
+
+// networksLock serializes the removal of the unused networks against the
+// container creations, which hold it from creating the missing networks
+// until the container is attached to them.
+var networksLock sync.RWMutex
+
+type dockerNetwork struct {
+	name     string
+	internal bool
+	driver   string
+	aliases  []string
+}
+
+// parseNetworks resolves the template variables of the networks in the options.
+func parseNetworks(opts *worker.ContainerOptions) []dockerNetwork {
+	if opts.Network == nil {
+		return nil
+	}
+
+	networks := make([]dockerNetwork, len(opts.Network.Networks))
+	for i, n := range opts.Network.Networks {
+		aliases := []string{opts.ContainerName}
+		for _, alias := range n.Aliases {
+			aliases = append(aliases, opts.Template(alias))
+		}
+
+		networks[i] = dockerNetwork{
+			name:     opts.Template(n.Name),
+			internal: n.Internal,
+			driver:   n.Driver,
+			aliases:  aliases,
+		}
+	}
+
+	return networks
+}
+
+// parseNetworkingConfig returns the network mode and endpoint of the first network,
+// as docker only allows attaching to one network when creating the container.
+func parseNetworkingConfig(opts *worker.ContainerOptions) (string, *network.NetworkingConfig) {
+	networks := parseNetworks(opts)
+	if len(networks) == 0 {
+		return "", nil
+	}
+
+	return networks[0].name, &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{
+			networks[0].name: {
+				Aliases: networks[0].aliases,
+			},
+		},
+	}
+}
+
+// ensureNetworks creates the networks in the options that don't exist yet.
+// The caller holds networksLock for reading.
+func ensureNetworks(ctx context.Context, c *dockerContainer, opts *worker.ContainerOptions) error {
+	for _, n := range parseNetworks(opts) {
+		_, err := c.client().NetworkInspect(ctx, n.name, types.NetworkInspectOptions{})
+		if err == nil {
+			continue
+		}
+		if !client.IsErrNotFound(err) {
+			return err
+		}
+
+		driver := n.driver
+		if driver == "" {
+			driver = "bridge"
+		}
+
+		c.Logger().Infof("Creating network %s...", n.name)
+		_, err = c.client().NetworkCreate(ctx, n.name, types.NetworkCreate{
+			CheckDuplicate: true,
+			Driver:         driver,
+			Internal:       n.internal,
+			Labels: map[string]string{
+				networkLabel: "true",
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// connectNetworks attaches the container to the remaining networks,
+// after being created attached to the first one.
+func connectNetworks(ctx context.Context, c *dockerContainer, id string, opts *worker.ContainerOptions) error {
+	networks := parseNetworks(opts)
+	if len(networks) < 2 {
+		return nil
+	}
+
+	for _, n := range networks[1:] {
+		err := c.client().NetworkConnect(ctx, n.name, id, &network.EndpointSettings{
+			Aliases: n.aliases,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cleanupNetworks removes the networks created by the worker
+// that are no longer in use by any container, stopped ones included.
+func cleanupNetworks(ctx context.Context, cli *client.Client) error {
+	networksLock.Lock()
+	defer networksLock.Unlock()
+
+	networks, err := cli.NetworkList(ctx, types.NetworkListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", networkLabel)),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, n := range networks {
+		// The network endpoints only list the running containers
+		containers, err := cli.ContainerList(ctx, types.ContainerListOptions{
+			All:     true,
+			Limit:   1,
+			Filters: filters.NewArgs(filters.Arg("network", n.ID)),
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(containers) > 0 {
+			continue
+		}
+
+		logger.Infof("Removing unused network %s...", n.Name)
+		if err := cli.NetworkRemove(ctx, n.ID); err != nil && !client.IsErrNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
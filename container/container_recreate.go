@@ -0,0 +1,113 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/PanelMc/worker"
+	"github.com/PanelMc/worker/node"
+)
+
+func (c *dockerContainer) Recreate(updated worker.ContainerOptions) ([]worker.SpecChange, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	current, err := worker.LoadSpec(c.ContainerName)
+	if err != nil {
+		// The spec may not be stored, e.g. without a spec store
+		current = c.options
+	}
+
+	if updated.ContainerName == "" {
+		updated.ContainerName = current.ContainerName
+	}
+	if updated.ContainerName != current.ContainerName {
+		return nil, fmt.Errorf("can't rename server '%s' to '%s' when recreating", current.ContainerName, updated.ContainerName)
+	}
+
+	// Keep the allocated ports, unless the allocation request changed
+	if updated.Network != nil {
+		network := *updated.Network
+		if current.Network != nil && reflect.DeepEqual(network.Allocate, current.Network.Allocate) {
+			network.Allocated = current.Network.Allocated
+		} else {
+			network.Allocated = nil
+		}
+		updated.Network = &network
+	}
+
+	// Keep the flags the container was created with
+	if updated.JVM != nil && current.JVM != nil {
+		jvm := *updated.JVM
+		jvm.Applied = current.JVM.Applied
+		updated.JVM = &jvm
+	}
+
+	// Keep the pinned image, unless the image changed
+	if updated.Image.ID != current.Image.ID || !reflect.DeepEqual(updated.Image.Build, current.Image.Build) {
+		updated.Image.Pinned, updated.Image.Digest = "", ""
+	} else {
+		updated.Image.Pinned, updated.Image.Digest = current.Image.Pinned, current.Image.Digest
+	}
+
+	if err := updated.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := node.AdmitRecreate(current, &updated); err != nil {
+		return nil, err
+	}
+
+	changes, err := worker.DiffSpecs(current, updated)
+	if err != nil {
+		return nil, err
+	}
+	// The JVM flags changed by a resize are applied by recreating
+	if updated.JVM != nil && updated.JVM.Stale(updated.Memory.Limit) {
+		args, _ := updated.JVM.Args(updated.Memory.Limit)
+		changes = append(changes, worker.SpecChange{
+			Option: "jvm_flags",
+			From:   strings.Join(updated.JVM.Applied, " "),
+			To:     strings.Join(args, " "),
+		})
+	}
+
+	if len(changes) == 0 {
+		c.Logger().Info("Spec unchanged, not recreating the container.")
+		return changes, nil
+	}
+
+	// The binds are kept, so the server data is preserved
+	for _, change := range changes {
+		if change.Option == "Binds" {
+			return nil, errors.New("the binds can't be changed when recreating")
+		}
+	}
+
+	ctx := context.Background()
+	c.Logger().Infof("Recreating the container, %d options changed...", len(changes))
+
+	if updated.Image.Pinned == "" {
+		if err := prepare(ctx, c, &updated); err != nil {
+			return nil, err
+		}
+
+		if err := pinImage(ctx, c, &updated); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.recreate(ctx, &updated); err != nil {
+		return nil, err
+	}
+
+	if err := cleanupNetworks(ctx, c.client()); err != nil {
+		c.Logger().Errorf("Failed to remove the unused networks: %s", err)
+	}
+
+	c.Logger().Info("Container recreated.")
+	return changes, nil
+}
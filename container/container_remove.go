@@ -0,0 +1,30 @@
+package container
+
+import (
+	"context"
+
+	"github.com/PanelMc/worker"
+	"github.com/docker/docker/api/types"
+)
+
+func (c *dockerContainer) Remove() error {
+	c.Logger().Debug("Removing the container...")
+
+	err := c.client().ContainerRemove(context.Background(), c.ContainerID, types.ContainerRemoveOptions{
+		Force: true,
+	})
+	if err != nil {
+		c.Logger().Error("Failed to remove the container.")
+		return containerError(c, err)
+	}
+
+	c.status = worker.StatusStopped
+	c.ReleaseAdmission()
+	c.Logger().Info("Container removed.")
+
+	if err := cleanupNetworks(context.Background(), c.client()); err != nil {
+		c.Logger().Errorf("Failed to remove the unused networks: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package container
+
+import (
+	"github.com/PanelMc/worker"
+	"github.com/PanelMc/worker/node"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/sirupsen/logrus"
+)
+
+// CreateRequest is the docker request used to create a container.
+type CreateRequest struct {
+	Name             string                    `json:"name"`
+	Config           *container.Config         `json:"config"`
+	HostConfig       *container.HostConfig     `json:"host_config"`
+	NetworkingConfig *network.NetworkingConfig `json:"networking_config"`
+	// Options are the resolved options the request was rendered from
+	Options worker.ContainerOptions `json:"options"`
+	// HostDirs are the templated host directories of the binds
+	HostDirs []string `json:"host_dirs"`
+}
+
+// Render runs the options through the same pipeline as NewDockerContainer,
+// returning the docker create request without touching docker.
+// Ports are allocated as they would be, without reserving them, and
+// the image is referenced by its tag, as it's pinned on creation.
+func Render(opts ...worker.ContainerOpts) (*CreateRequest, error) {
+	options := defaultOptions()
+
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	release, err := node.Admit(options)
+	if err != nil {
+		return nil, err
+	}
+	release()
+
+	c := &dockerContainer{
+		ContainerName: options.ContainerName,
+		status:        worker.StatusStopped,
+		options:       *options,
+		logger:        logrus.WithField("container", options.ContainerName),
+	}
+
+	req, err := c.createRequest(options)
+	if err != nil {
+		return nil, err
+	}
+	req.Options = *options
+	req.HostDirs = node.HostDirs(*options)
+
+	return req, nil
+}
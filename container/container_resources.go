@@ -0,0 +1,69 @@
+package container
+
+import (
+	"github.com/PanelMc/worker"
+	"github.com/docker/docker/api/types/blkiodev"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-units"
+)
+
+func parseResources(c *dockerContainer, opts *worker.ContainerOptions) (container.Resources, error) {
+	memory, err := opts.Memory.Bytes()
+	if err != nil {
+		return container.Resources{}, err
+	}
+
+	readBps, err := parseDeviceRates(opts.IO.ReadBps)
+	if err != nil {
+		return container.Resources{}, err
+	}
+	writeBps, err := parseDeviceRates(opts.IO.WriteBps)
+	if err != nil {
+		return container.Resources{}, err
+	}
+
+	ulimits := make([]*units.Ulimit, len(opts.Ulimits))
+	for i, ulimit := range opts.Ulimits {
+		ulimits[i] = &units.Ulimit{
+			Name: ulimit.Name,
+			Soft: ulimit.Soft,
+			Hard: ulimit.Hard,
+		}
+	}
+
+	resources := container.Resources{
+		Memory:              int64(memory.Limit),
+		MemorySwap:          memory.DockerSwap(),
+		MemoryReservation:   int64(memory.Reservation),
+		NanoCPUs:            int64(opts.CPU.Cores * 1e9),
+		CPUShares:           opts.CPU.Shares,
+		CpusetCpus:          opts.CPU.Cpuset,
+		BlkioWeight:         opts.IO.Weight,
+		BlkioDeviceReadBps:  readBps,
+		BlkioDeviceWriteBps: writeBps,
+		Ulimits:             ulimits,
+	}
+
+	if opts.PidsLimit > 0 {
+		resources.PidsLimit = &opts.PidsLimit
+	}
+
+	return resources, nil
+}
+
+func parseDeviceRates(rates []worker.ContainerDeviceRate) ([]*blkiodev.ThrottleDevice, error) {
+	devices := make([]*blkiodev.ThrottleDevice, len(rates))
+	for i, rate := range rates {
+		bytes, err := rate.Bytes()
+		if err != nil {
+			return nil, err
+		}
+
+		devices[i] = &blkiodev.ThrottleDevice{
+			Path: rate.Device,
+			Rate: bytes,
+		}
+	}
+
+	return devices, nil
+}
@@ -0,0 +1,46 @@
+package container
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/PanelMc/worker"
+	"github.com/docker/docker/api/types/container"
+)
+
+// parseSecurity applies the security options to the host config.
+// Without security options, the hardened profile is applied.
+func parseSecurity(opts *worker.ContainerOptions, hostConfig *container.HostConfig) error {
+	security := opts.Security
+	if security == nil {
+		security = &worker.ContainerSecurity{Profile: worker.SecurityProfileHardened}
+	}
+
+	hostConfig.CapDrop, hostConfig.CapAdd = security.Capabilities()
+	hostConfig.ReadonlyRootfs = security.ReadOnlyRootfs
+	hostConfig.UsernsMode = container.UsernsMode(security.UsernsMode)
+
+	if security.NoNewPrivs() {
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "no-new-privileges")
+	}
+
+	if security.SeccompProfile != "" {
+		profile := security.SeccompProfile
+		if profile != "unconfined" {
+			// The daemon expects the profile contents, not its path
+			b, err := ioutil.ReadFile(profile)
+			if err != nil {
+				return fmt.Errorf("failed to read the seccomp profile: %w", err)
+			}
+			profile = string(b)
+		}
+
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "seccomp="+profile)
+	}
+
+	if security.AppArmorProfile != "" {
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "apparmor="+security.AppArmorProfile)
+	}
+
+	return nil
+}
@@ -2,22 +2,37 @@ package container
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/PanelMc/worker"
+	"github.com/PanelMc/worker/node"
 	"github.com/docker/docker/api/types"
 )
 
 func (c *dockerContainer) Start() error {
+	return c.StartContext(context.Background())
+}
+
+func (c *dockerContainer) StartContext(ctx context.Context) error {
 	c.logger.Debug("Starting the container...")
 
-	if c.status != worker.StatusStopped {
-		return fmt.Errorf("Server already running. Current status: %s", c.status)
+	switch c.status {
+	case worker.StatusStopped:
+	case worker.StatusStarting, worker.StatusStopping:
+		return worker.NewStatusError(c.Name(), c.status, worker.ErrTransitionInProgress)
+	default:
+		return worker.NewStatusError(c.Name(), c.status, worker.ErrAlreadyRunning)
+	}
+
+	if c.options.Disk != nil && c.options.Disk.BlockStart {
+		if err := node.CheckDiskQuota(c.options); err != nil {
+			c.Logger().Errorf("Failed to start the container: %s", err)
+			return err
+		}
 	}
 
-	if err := c.client.ContainerStart(context.TODO(), c.ContainerID, types.ContainerStartOptions{}); err != nil {
+	if err := c.client().ContainerStart(ctx, c.ContainerID, types.ContainerStartOptions{}); err != nil {
 		c.Logger().Error("Failed to start the container.")
-		return err
+		return containerError(c, err)
 	}
 
 	c.status = worker.StatusRunning
@@ -3,38 +3,203 @@ package container
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/PanelMc/worker"
+	"github.com/PanelMc/worker/node"
 	"github.com/docker/docker/api/types"
 )
 
 func (c *dockerContainer) Stats() (worker.ContainerStats, error) {
-	stats, err := c.stats(false, time.Millisecond*100)
+	return c.StatsContext(context.Background())
+}
+
+func (c *dockerContainer) StatsContext(ctx context.Context) (worker.ContainerStats, error) {
+	stats, err := c.stats(ctx, false, time.Millisecond*100)
 	if err != nil {
 		return worker.ContainerStats{}, err
 	}
 
-	return *<-stats, nil
+	select {
+	case <-ctx.Done():
+		return worker.ContainerStats{}, ctx.Err()
+	case s, ok := <-stats:
+		if !ok {
+			if err := ctx.Err(); err != nil {
+				return worker.ContainerStats{}, err
+			}
+			return worker.ContainerStats{}, errors.New("no stats received from the container")
+		}
+		return *s, nil
+	}
 }
 
 func (c *dockerContainer) StatsChan() (<-chan *worker.ContainerStats, error) {
-	if c.statsChan == nil {
-		stats, err := c.stats(true, time.Second*1)
+	return c.StatsChanContext(context.Background())
+}
+
+// StatsChanContext subscribes to the stats stream of the container, shared by
+// all the subscribers, until the context is done. The stream is closed
+// once the last subscriber leaves.
+func (c *dockerContainer) StatsChanContext(ctx context.Context) (<-chan *worker.ContainerStats, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.statsFeed == nil {
+		feedCtx, cancel := context.WithCancel(context.Background())
+		stats, err := c.stats(feedCtx, true, time.Second*1)
 		if err != nil {
+			cancel()
 			return nil, err
 		}
 
-		c.statsChan = stats
+		c.statsFeed = &statsFeed{
+			cancel:      cancel,
+			subscribers: make(map[*statsSubscriber]bool),
+		}
+		go c.forwardStats(feedCtx, c.statsFeed, c.ContainerID, stats)
 	}
 
-	return c.statsChan, nil
+	feed := c.statsFeed
+	sub := feed.subscribe()
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.unsubscribeStats(feed, sub)
+		case <-sub.closed:
+		}
+	}()
+
+	return sub.events, nil
+}
+
+// statsFeed fans the stats stream of the container out to its subscribers.
+type statsFeed struct {
+	sync.Mutex
+	cancel      context.CancelFunc
+	subscribers map[*statsSubscriber]bool
 }
 
-func (c *dockerContainer) stats(stream bool, delay time.Duration) (<-chan *worker.ContainerStats, error) {
-	stats, err := c.client.ContainerStats(context.TODO(), c.ContainerID, false)
+type statsSubscriber struct {
+	events chan *worker.ContainerStats
+	// closed when the subscriber leaves, or the feed ends
+	closed chan struct{}
+}
+
+func (f *statsFeed) subscribe() *statsSubscriber {
+	f.Lock()
+	defer f.Unlock()
+
+	sub := &statsSubscriber{
+		events: make(chan *worker.ContainerStats, 8),
+		closed: make(chan struct{}),
+	}
+	f.subscribers[sub] = true
+
+	return sub
+}
+
+// remove removes the subscriber, returning the number of subscribers left.
+func (f *statsFeed) remove(sub *statsSubscriber) int {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.subscribers[sub] {
+		delete(f.subscribers, sub)
+		close(sub.events)
+		close(sub.closed)
+	}
+
+	return len(f.subscribers)
+}
+
+// broadcast sends the stats to every subscriber, dropping them for the
+// subscribers still behind, so a slow one doesn't hold back the others.
+func (f *statsFeed) broadcast(stats *worker.ContainerStats) {
+	f.Lock()
+	defer f.Unlock()
+
+	for sub := range f.subscribers {
+		select {
+		case sub.events <- stats:
+		default:
+		}
+	}
+}
+
+// close closes the channels of all the subscribers.
+func (f *statsFeed) close() {
+	f.Lock()
+	defer f.Unlock()
+
+	for sub := range f.subscribers {
+		delete(f.subscribers, sub)
+		close(sub.events)
+		close(sub.closed)
+	}
+}
+
+// unsubscribeStats removes the subscriber from the feed,
+// stopping the stats stream when it was the last one.
+func (c *dockerContainer) unsubscribeStats(feed *statsFeed, sub *statsSubscriber) {
+	c.Lock()
+	defer c.Unlock()
+
+	if feed.remove(sub) == 0 {
+		feed.cancel()
+		if c.statsFeed == feed {
+			c.statsFeed = nil
+		}
+	}
+}
+
+// forwardStats broadcasts the stats stream of the container, reopening it
+// when the container is recreated, so the subscribers keep the same channel.
+func (c *dockerContainer) forwardStats(ctx context.Context, feed *statsFeed, id string, stats <-chan *worker.ContainerStats) {
+	defer feed.close()
+
+	for {
+		for s := range stats {
+			feed.broadcast(s)
+		}
+
+		if ctx.Err() != nil {
+			// All the subscribers left
+			return
+		}
+
+		// Recreating holds the lock, so the new container exists once acquired
+		c.Lock()
+		if c.ContainerID == id {
+			// The container was removed
+			if c.statsFeed == feed {
+				c.statsFeed = nil
+			}
+			c.Unlock()
+			return
+		}
+
+		var err error
+		id = c.ContainerID
+		stats, err = c.stats(ctx, true, time.Second*1)
+		if err != nil {
+			c.Logger().Errorf("Failed to reopen the stats stream: %s", err)
+			if c.statsFeed == feed {
+				c.statsFeed = nil
+			}
+			c.Unlock()
+			return
+		}
+		c.Unlock()
+	}
+}
+
+func (c *dockerContainer) stats(ctx context.Context, stream bool, delay time.Duration) (<-chan *worker.ContainerStats, error) {
+	stats, err := c.client().ContainerStats(ctx, c.ContainerID, stream)
 	if err != nil {
 		return nil, err
 	}
@@ -53,7 +218,7 @@ func (c *dockerContainer) stats(stream bool, delay time.Duration) (<-chan *worke
 
 		for {
 			if err := dec.Decode(&v); err != nil {
-				if err == io.EOF {
+				if err == io.EOF || ctx.Err() != nil {
 					// No more content, exit loop and close everything
 					break
 				}
@@ -66,7 +231,16 @@ func (c *dockerContainer) stats(stream bool, delay time.Duration) (<-chan *worke
 				continue
 			}
 
-			statsChan <- mapStats(daemonOSType, v)
+			s := mapStats(daemonOSType, v)
+			if usage, ok := node.GetDiskUsage(c.ContainerName); ok {
+				s.DiskUsage = usage.Usage
+				s.DiskQuota = usage.Quota
+			}
+			select {
+			case statsChan <- s:
+			case <-ctx.Done():
+				return
+			}
 
 			if !stream {
 				break
@@ -2,25 +2,28 @@ package container
 
 import (
 	"context"
-	"fmt"
 	"time"
 
 	"github.com/PanelMc/worker"
 )
 
 func (c *dockerContainer) Stop() error {
+	return c.StopContext(context.Background())
+}
+
+func (c *dockerContainer) StopContext(ctx context.Context) error {
 	c.Logger().Debug("Stopping the server...")
 	
-	if c.status == worker.StatusStopping {
-		return fmt.Errorf("Server already shutting down. Current status: %s", c.status)
+	if c.status == worker.StatusStopping || c.status == worker.StatusStarting {
+		return worker.NewStatusError(c.Name(), c.status, worker.ErrTransitionInProgress)
 	} else if c.status == worker.StatusStopped {
-		return fmt.Errorf("Server already stopped. Current status: %s", c.status)
+		return worker.NewStatusError(c.Name(), c.status, worker.ErrAlreadyStopped)
 	}
 
 	timeout := time.Duration(time.Second * 15)
-	if err := c.client.ContainerStop(context.TODO(), c.ContainerID, &timeout); err != nil {
+	if err := c.client().ContainerStop(ctx, c.ContainerID, &timeout); err != nil {
 		c.Logger().Error("Failed to stop the container.")
-		return err
+		return containerError(c, err)
 	}
 
 	return nil
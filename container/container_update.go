@@ -0,0 +1,140 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/PanelMc/worker"
+	"github.com/PanelMc/worker/node"
+	"github.com/docker/docker/api/types/container"
+)
+
+func (c *dockerContainer) UpdateResources(update worker.ResourceUpdate) ([]worker.ResourceChange, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	options := c.options
+	if update.Memory != nil {
+		if update.Memory.Limit != "" {
+			options.Memory.Limit = update.Memory.Limit
+		}
+
+		if update.Memory.Swap != "" {
+			options.Memory.Swap = update.Memory.Swap
+		}
+
+		if update.Memory.Reservation != "" {
+			options.Memory.Reservation = update.Memory.Reservation
+		}
+	}
+	if update.CPU != nil {
+		options.CPU = *update.CPU
+	}
+
+	changes := diffResources(c.options, options, c.status != worker.StatusStopped)
+	if len(changes) == 0 {
+		return changes, nil
+	}
+
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := node.AdmitResize(c.options, options); err != nil {
+		return nil, err
+	}
+
+	resources, err := parseResources(c, &options)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Logger().Debug("Updating the container resources...")
+	_, err = c.client().ContainerUpdate(context.Background(), c.ContainerID, container.UpdateConfig{
+		Resources: container.Resources{
+			Memory:            resources.Memory,
+			MemorySwap:        resources.MemorySwap,
+			MemoryReservation: resources.MemoryReservation,
+			NanoCPUs:          resources.NanoCPUs,
+			CPUShares:         resources.CPUShares,
+			CpusetCpus:        resources.CpusetCpus,
+		},
+	})
+	if err != nil {
+		c.Logger().Error("Failed to update the container resources.")
+		return nil, err
+	}
+
+	// The memory limit is persisted, so the recomputed JVM flags are
+	// applied the next time the container is recreated
+	c.options = options
+	if err := worker.SaveSpec(options); err != nil {
+		c.Logger().Errorf("Failed to save the container spec: %s", err)
+	}
+
+	if hasChange(changes, "jvm_flags") {
+		c.Logger().Info("JVM flags changed, the container must be recreated to apply them.")
+	}
+
+	c.Logger().Info("Container resources updated.")
+	return changes, nil
+}
+
+func hasChange(changes []worker.ResourceChange, resource string) bool {
+	for _, change := range changes {
+		if change.Resource == resource {
+			return true
+		}
+	}
+
+	return false
+}
+
+func diffResources(from, to worker.ContainerOptions, running bool) []worker.ResourceChange {
+	changes := make([]worker.ResourceChange, 0)
+	// add appends the change if the values differ. A non empty
+	// reason means the change requires a restart to take effect.
+	add := func(resource string, from, to interface{}, requiresRestart bool, reason string) {
+		f, t := fmt.Sprint(from), fmt.Sprint(to)
+		if f == t {
+			return
+		}
+
+		change := worker.ResourceChange{
+			Resource: resource,
+			From:     f,
+			To:       t,
+		}
+		if requiresRestart {
+			change.RequiresRestart = true
+			change.Reason = reason
+		}
+
+		changes = append(changes, change)
+	}
+
+	add("memory", from.Memory.Limit, to.Memory.Limit, running,
+		"the server process sizes its memory at startup, e.g. JVM -Xmx")
+	add("swap", from.Memory.Swap, to.Memory.Swap, false, "")
+	add("memory_reservation", from.Memory.Reservation, to.Memory.Reservation, false, "")
+	add("cpu_cores", from.CPU.Cores, to.CPU.Cores, false, "")
+	add("cpu_shares", from.CPU.Shares, to.CPU.Shares, false, "")
+	add("cpuset", from.CPU.Cpuset, to.CPU.Cpuset, running,
+		"the server process sizes its thread pools at startup")
+
+	if to.JVM != nil {
+		var fromArgs, toArgs []string
+		if from.JVM != nil {
+			fromArgs, _ = from.JVM.Args(from.Memory.Limit)
+		}
+		toArgs, _ = to.JVM.Args(to.Memory.Limit)
+
+		// The flags are applied when the container is created, so
+		// they require recreating it, even if it's not running
+		add("jvm_flags", strings.Join(fromArgs, " "), strings.Join(toArgs, " "), true,
+			"the JVM flags are set when the container is created, applied on the next recreate")
+	}
+
+	return changes
+}
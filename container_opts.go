@@ -27,6 +27,10 @@ func WithPreset(preset ServerPreset) ContainerOpts {
 			if preset.Memory.Swap != "" {
 				co.Memory.Swap = preset.Memory.Swap
 			}
+
+			if preset.Memory.Reservation != "" {
+				co.Memory.Reservation = preset.Memory.Reservation
+			}
 		}
 
 		if preset.Network != nil {
@@ -36,5 +40,88 @@ func WithPreset(preset ServerPreset) ContainerOpts {
 		if len(preset.Binds) > 0 {
 			co.Binds = preset.Binds
 		}
+
+		if preset.CPU != nil {
+			if preset.CPU.Cores != 0 {
+				co.CPU.Cores = preset.CPU.Cores
+			}
+
+			if preset.CPU.Shares != 0 {
+				co.CPU.Shares = preset.CPU.Shares
+			}
+
+			if preset.CPU.Cpuset != "" {
+				co.CPU.Cpuset = preset.CPU.Cpuset
+			}
+		}
+
+		if preset.IO != nil {
+			if preset.IO.Weight != 0 {
+				co.IO.Weight = preset.IO.Weight
+			}
+
+			if len(preset.IO.ReadBps) > 0 {
+				co.IO.ReadBps = preset.IO.ReadBps
+			}
+
+			if len(preset.IO.WriteBps) > 0 {
+				co.IO.WriteBps = preset.IO.WriteBps
+			}
+		}
+
+		if preset.PidsLimit != 0 {
+			co.PidsLimit = preset.PidsLimit
+		}
+
+		for _, ulimit := range preset.Ulimits {
+			co.Ulimits = setUlimit(co.Ulimits, ulimit)
+		}
+
+		if preset.JVM != nil {
+			co.JVM = preset.JVM
+		}
+
+		if preset.Owner != nil {
+			co.Owner = preset.Owner
+		}
+
+		if preset.Disk != nil {
+			co.Disk = preset.Disk
+		}
+
+		if preset.Security != nil {
+			co.Security = preset.Security
+		}
+
+		if len(preset.Alerts) > 0 {
+			co.Alerts = preset.Alerts
+		}
+
+		if len(preset.Env) > 0 {
+			env := make(map[string]string, len(co.Env)+len(preset.Env))
+			for k, v := range co.Env {
+				env[k] = v
+			}
+			for k, v := range preset.Env {
+				env[k] = v
+			}
+			co.Env = env
+		}
+	}
+}
+
+// setUlimit replaces the ulimit with the same name, or appends it
+// if not present.
+func setUlimit(ulimits []ContainerUlimit, ulimit ContainerUlimit) []ContainerUlimit {
+	for i, u := range ulimits {
+		if u.Name == ulimit.Name {
+			result := make([]ContainerUlimit, len(ulimits))
+			copy(result, ulimits)
+			result[i] = ulimit
+
+			return result
+		}
 	}
+
+	return append(ulimits[:len(ulimits):len(ulimits)], ulimit)
 }
@@ -0,0 +1,363 @@
+package worker
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/bytefmt"
+)
+
+// Validate checks whether the options are valid to create a container.
+func (o ContainerOptions) Validate() error {
+	if err := o.Image.Validate(); err != nil {
+		return err
+	}
+
+	if _, err := o.Memory.Bytes(); err != nil {
+		return err
+	}
+
+	for _, bind := range o.Binds {
+		if err := bind.Validate(o.Template(bind.HostDir)); err != nil {
+			return err
+		}
+	}
+
+	if err := validateBindTargets(o.Binds); err != nil {
+		return err
+	}
+
+	if o.Owner != nil && (o.Owner.UID < 0 || o.Owner.GID < 0) {
+		return fmt.Errorf("invalid owner %d:%d: ids must not be negative", o.Owner.UID, o.Owner.GID)
+	}
+
+	if o.Disk != nil {
+		if err := o.Disk.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if o.Security != nil {
+		if err := o.Security.Validate(); err != nil {
+			return err
+		}
+
+		if o.Security.ReadOnlyRootfs && !hasWritableBind(o.Binds) {
+			return fmt.Errorf("invalid security options: a read only root filesystem requires a writable bind")
+		}
+	}
+
+	if _, err := o.Network.PortBindings(); err != nil {
+		return err
+	}
+
+	if o.Network != nil && o.Network.Allocate != nil {
+		if err := o.Network.Allocate.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if o.Network != nil {
+		names := make(map[string]bool)
+		for _, network := range o.Network.Networks {
+			name := o.Template(network.Name)
+			if err := network.Validate(name); err != nil {
+				return err
+			}
+
+			if names[name] {
+				return fmt.Errorf("invalid docker network '%s': defined more than once", name)
+			}
+			names[name] = true
+		}
+	}
+
+	if err := o.CPU.Validate(); err != nil {
+		return err
+	}
+
+	if err := o.IO.Validate(); err != nil {
+		return err
+	}
+
+	if o.PidsLimit < 0 {
+		return fmt.Errorf("invalid pids limit %d: must not be negative", o.PidsLimit)
+	}
+
+	if o.JVM != nil {
+		if err := o.JVM.Validate(); err != nil {
+			return err
+		}
+
+		if _, err := o.JVM.Heap(o.Memory.Limit); err != nil {
+			return err
+		}
+	}
+
+	names := make(map[string]bool)
+	for _, ulimit := range o.Ulimits {
+		if err := ulimit.Validate(); err != nil {
+			return err
+		}
+
+		if names[ulimit.Name] {
+			return fmt.Errorf("invalid ulimit '%s': defined more than once", ulimit.Name)
+		}
+		names[ulimit.Name] = true
+	}
+
+	return nil
+}
+
+// Validate checks whether the defined options are valid,
+// allowing presets to be validated before creating servers.
+// Unlike ContainerOptions, the memory limit may be omitted.
+func (o ServerCreateOptions) Validate() error {
+	if err := validateBindTargets(o.Binds); err != nil {
+		return err
+	}
+
+	if o.ContainerImage != nil {
+		if err := o.ContainerImage.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if o.Memory != nil {
+		memory := *o.Memory
+		if memory.Limit == "" {
+			// Validate the remaining values against a limit big enough
+			memory.Limit = "1TB"
+		}
+
+		if _, err := memory.Bytes(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := o.Network.PortBindings(); err != nil {
+		return err
+	}
+
+	if o.Network != nil && o.Network.Allocate != nil {
+		if err := o.Network.Allocate.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if o.CPU != nil {
+		if err := o.CPU.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if o.IO != nil {
+		if err := o.IO.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if o.PidsLimit < 0 {
+		return fmt.Errorf("invalid pids limit %d: must not be negative", o.PidsLimit)
+	}
+
+	names := make(map[string]bool)
+	for _, ulimit := range o.Ulimits {
+		if err := ulimit.Validate(); err != nil {
+			return err
+		}
+
+		if names[ulimit.Name] {
+			return fmt.Errorf("invalid ulimit '%s': defined more than once", ulimit.Name)
+		}
+		names[ulimit.Name] = true
+	}
+
+	if o.JVM != nil {
+		if err := o.JVM.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if o.Disk != nil {
+		if err := o.Disk.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if o.Security != nil {
+		if err := o.Security.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Validate checks whether the preset options are valid.
+func (p ServerPreset) Validate() error {
+	return ServerCreateOptions(p).Validate()
+}
+
+func hasWritableBind(binds []ContainerBind) bool {
+	for _, bind := range binds {
+		if bind.Mode != "ro" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Validate checks whether the disk quota is valid.
+func (d ContainerDisk) Validate() error {
+	if _, err := d.QuotaBytes(); err != nil {
+		return err
+	}
+
+	if p := d.WarningPercentage; p != nil && (*p < 0 || *p > 100) {
+		return fmt.Errorf("invalid disk warning percentage %g: must be between 0 and 100", *p)
+	}
+
+	return nil
+}
+
+// QuotaBytes returns the disk quota, in bytes.
+func (d ContainerDisk) QuotaBytes() (uint64, error) {
+	quota, err := bytefmt.ToBytes(d.Quota)
+	if err != nil {
+		return 0, fmt.Errorf("invalid disk quota '%s': %w", d.Quota, err)
+	}
+
+	return quota, nil
+}
+
+// WarningBytes returns the usage, in bytes, at which to warn the server,
+// and false if the warning is disabled.
+func (d ContainerDisk) WarningBytes() (uint64, bool, error) {
+	quota, err := d.QuotaBytes()
+	if err != nil {
+		return 0, false, err
+	}
+
+	percentage := 90.0
+	if d.WarningPercentage != nil {
+		percentage = *d.WarningPercentage
+	}
+	if percentage == 0 {
+		return 0, false, nil
+	}
+
+	return uint64(float64(quota) * percentage / 100), true, nil
+}
+
+var networkNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+var reservedNetworks = map[string]bool{
+	"bridge": true,
+	"host":   true,
+	"none":   true,
+}
+
+// Validate checks whether the network, with the given resolved name, is valid.
+func (n ContainerDockerNetwork) Validate(name string) error {
+	if !networkNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid docker network name '%s'", name)
+	}
+
+	if reservedNetworks[name] {
+		return fmt.Errorf("invalid docker network '%s': predefined networks can't be used", name)
+	}
+
+	return nil
+}
+
+var cpusetRegex = regexp.MustCompile(`^\d+(-\d+)?(,\d+(-\d+)?)*$`)
+
+// Validate checks whether the CPU limits are valid.
+func (c ContainerCPU) Validate() error {
+	if c.Cores < 0 {
+		return fmt.Errorf("invalid cpu cores %g: must not be negative", c.Cores)
+	}
+
+	if c.Shares != 0 && (c.Shares < 2 || c.Shares > 262144) {
+		return fmt.Errorf("invalid cpu shares %d: must be between 2 and 262144", c.Shares)
+	}
+
+	if c.Cpuset != "" {
+		if !cpusetRegex.MatchString(c.Cpuset) {
+			return fmt.Errorf("invalid cpuset '%s': expected a list like '0-3' or '0,2'", c.Cpuset)
+		}
+
+		for _, r := range strings.Split(c.Cpuset, ",") {
+			bounds := strings.SplitN(r, "-", 2)
+			if len(bounds) != 2 {
+				continue
+			}
+
+			start, _ := strconv.Atoi(bounds[0])
+			end, _ := strconv.Atoi(bounds[1])
+			if start > end {
+				return fmt.Errorf("invalid cpuset '%s': range '%s' is reversed", c.Cpuset, r)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Validate checks whether the block IO limits are valid.
+func (c ContainerIO) Validate() error {
+	if c.Weight != 0 && (c.Weight < 10 || c.Weight > 1000) {
+		return fmt.Errorf("invalid io weight %d: must be between 10 and 1000", c.Weight)
+	}
+
+	for _, rate := range append(c.ReadBps[:len(c.ReadBps):len(c.ReadBps)], c.WriteBps...) {
+		if _, err := rate.Bytes(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Bytes returns the rate, in bytes per second.
+func (r ContainerDeviceRate) Bytes() (uint64, error) {
+	if !filepath.IsAbs(r.Device) {
+		return 0, fmt.Errorf("invalid io device '%s': must be an absolute path", r.Device)
+	}
+
+	rate, err := bytefmt.ToBytes(r.Rate)
+	if err != nil {
+		return 0, fmt.Errorf("invalid io rate '%s' for device '%s': %w", r.Rate, r.Device, err)
+	}
+
+	return rate, nil
+}
+
+var ulimitNames = map[string]bool{
+	"core": true, "cpu": true, "data": true, "fsize": true,
+	"locks": true, "memlock": true, "msgqueue": true, "nice": true,
+	"nofile": true, "nproc": true, "rss": true, "rtprio": true,
+	"rttime": true, "sigpending": true, "stack": true,
+}
+
+// Validate checks whether the ulimit is valid.
+func (u ContainerUlimit) Validate() error {
+	if !ulimitNames[u.Name] {
+		return fmt.Errorf("invalid ulimit: unknown name '%s'", u.Name)
+	}
+
+	if u.Soft < 0 || u.Hard < 0 {
+		return fmt.Errorf("invalid ulimit '%s': values must not be negative", u.Name)
+	}
+
+	if u.Soft > u.Hard {
+		return fmt.Errorf("invalid ulimit '%s': soft limit %d greater than hard limit %d", u.Name, u.Soft, u.Hard)
+	}
+
+	return nil
+}
@@ -0,0 +1,244 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	docker "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/versions"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+var logger = logrus.WithField("context", "engine")
+
+// MinAPIVersion is the oldest docker API version supported, of docker 17.12.
+const MinAPIVersion = "1.35"
+
+// Reconnection backoff bounds
+const (
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+// Runtime owns the docker client shared by the worker, negotiating
+// the API version with the daemon, and reconnecting when the daemon
+// restarts.
+type Runtime struct {
+	sync.RWMutex
+	cli     *client.Client
+	version docker.Version
+	healthy bool
+	closed  bool
+	// cancel stops the health check and the reconnection
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Connect connects to the docker daemon configured by the environment,
+// failing if its API version is older than MinAPIVersion.
+func Connect(ctx context.Context) (*Runtime, error) {
+	cli, version, err := connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Infof("Connected to docker %s (API %s).", version.Version, cli.ClientVersion())
+	return &Runtime{
+		cli:     cli,
+		version: version,
+		healthy: true,
+	}, nil
+}
+
+func connect(ctx context.Context) (*client.Client, docker.Version, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, docker.Version{}, fmt.Errorf("failed to create the docker client: %w", err)
+	}
+
+	version, err := cli.ServerVersion(ctx)
+	if err != nil {
+		cli.Close()
+		return nil, docker.Version{}, fmt.Errorf("failed to connect to the docker daemon: %w", err)
+	}
+
+	if versions.LessThan(version.APIVersion, MinAPIVersion) {
+		cli.Close()
+		return nil, docker.Version{}, fmt.Errorf("docker %s (API %s) is not supported, the minimum API version is %s",
+			version.Version, version.APIVersion, MinAPIVersion)
+	}
+
+	return cli, version, nil
+}
+
+// Client returns the current docker client, replaced on reconnections.
+func (r *Runtime) Client() *client.Client {
+	r.RLock()
+	defer r.RUnlock()
+
+	return r.cli
+}
+
+// Version returns the version of the docker daemon, as of the last connection.
+func (r *Runtime) Version() docker.Version {
+	r.RLock()
+	defer r.RUnlock()
+
+	return r.version
+}
+
+// Healthy returns whether the daemon responded to the last health check.
+func (r *Runtime) Healthy() bool {
+	r.RLock()
+	defer r.RUnlock()
+
+	return r.healthy && !r.closed
+}
+
+// StartHealthCheck periodically pings the daemon, reconnecting with
+// an exponential backoff when it stops responding, until the runtime
+// is closed.
+func (r *Runtime) StartHealthCheck(interval time.Duration) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.closed || r.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := r.ping(ctx, interval); err != nil && ctx.Err() == nil {
+				logger.Warnf("Docker daemon not responding: %s", err)
+				r.reconnect(ctx)
+			}
+		}
+	}()
+}
+
+func (r *Runtime) ping(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	_, err := r.Client().Ping(ctx)
+	return err
+}
+
+// reconnect replaces the client once the daemon is back,
+// renegotiating the API version in case it was upgraded.
+func (r *Runtime) reconnect(ctx context.Context) {
+	r.Lock()
+	r.healthy = false
+	r.Unlock()
+
+	backoff := minBackoff
+	for {
+		cli, version, err := connect(ctx)
+		if err == nil {
+			r.Lock()
+			previous := r.cli
+			r.cli = cli
+			r.version = version
+			r.healthy = true
+			r.Unlock()
+
+			// In-flight requests keep working, only the idle connections are closed
+			previous.Close()
+			logger.Infof("Reconnected to docker %s (API %s).", version.Version, cli.ClientVersion())
+			return
+		}
+		logger.Debugf("Failed to reconnect to the docker daemon, retrying in %s: %s", backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// Close stops the health check and closes the client.
+func (r *Runtime) Close() error {
+	r.Lock()
+	if r.closed {
+		r.Unlock()
+		return nil
+	}
+	r.closed = true
+	cancel, done := r.cancel, r.done
+	r.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+
+	return r.Client().Close()
+}
+
+var defaultRuntime struct {
+	sync.Mutex
+	runtime *Runtime
+}
+
+// SetDefault sets the runtime shared by the worker.
+func SetDefault(r *Runtime) {
+	defaultRuntime.Lock()
+	defer defaultRuntime.Unlock()
+
+	defaultRuntime.runtime = r
+}
+
+// Default returns the runtime shared by the worker,
+// connecting it if it wasn't set yet.
+func Default() (*Runtime, error) {
+	defaultRuntime.Lock()
+	defer defaultRuntime.Unlock()
+
+	if defaultRuntime.runtime != nil {
+		return defaultRuntime.runtime, nil
+	}
+
+	r, err := Connect(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defaultRuntime.runtime = r
+
+	return r, nil
+}
+
+// Close closes the runtime shared by the worker, if connected.
+func Close() error {
+	defaultRuntime.Lock()
+	r := defaultRuntime.runtime
+	defaultRuntime.runtime = nil
+	defaultRuntime.Unlock()
+
+	if r == nil {
+		return nil
+	}
+
+	return r.Close()
+}
@@ -0,0 +1,135 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors returned by the servers and containers, to be checked with errors.Is.
+var (
+	// ErrAlreadyRunning is returned when starting a server already running.
+	ErrAlreadyRunning = errors.New("server already running")
+	// ErrAlreadyStopped is returned when stopping a server already stopped.
+	ErrAlreadyStopped = errors.New("server already stopped")
+	// ErrTransitionInProgress is returned when the server is still starting or stopping.
+	ErrTransitionInProgress = errors.New("server transition in progress")
+	// ErrNotFound is returned when the server, or its container, doesn't exist.
+	ErrNotFound = errors.New("server not found")
+	// ErrImagePull is returned when the server image couldn't be pulled.
+	ErrImagePull = errors.New("image pull failed")
+	// ErrResourceRejected is returned when the node can't provide the resources of a server.
+	ErrResourceRejected = errors.New("resources rejected")
+)
+
+// StatusError is returned when the server status doesn't allow an operation.
+// It matches ErrAlreadyRunning, ErrAlreadyStopped or ErrTransitionInProgress.
+type StatusError struct {
+	Server string `json:"server"`
+	Status Status `json:"status"`
+	Err    error  `json:"-"`
+}
+
+// NewStatusError returns the error of an operation not allowed in the given server status.
+func NewStatusError(server string, status Status, err error) *StatusError {
+	return &StatusError{Server: server, Status: status, Err: err}
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: '%s' is %s", e.Err, e.Server, e.Status)
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// NotFoundError is returned when the server, or its container, doesn't exist.
+// It matches ErrNotFound.
+type NotFoundError struct {
+	Server string `json:"server"`
+	Err    error  `json:"-"`
+}
+
+func (e *NotFoundError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("server '%s' not found: %s", e.Server, e.Err)
+	}
+	return fmt.Sprintf("server '%s' not found", e.Server)
+}
+
+func (e *NotFoundError) Unwrap() error {
+	return e.Err
+}
+
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// ImagePullError is returned when the server image couldn't be pulled.
+// It matches ErrImagePull.
+type ImagePullError struct {
+	Image string `json:"image"`
+	// Message is the error message of the registry, if the pull failed there
+	Message string `json:"message,omitempty"`
+	Err     error  `json:"-"`
+}
+
+func (e *ImagePullError) Error() string {
+	switch {
+	case e.Message != "":
+		return fmt.Sprintf("image pull error for '%s': %s", e.Image, e.Message)
+	case e.Err != nil:
+		return fmt.Sprintf("image pull error for '%s': %s", e.Image, e.Err)
+	}
+	return fmt.Sprintf("image pull error for '%s'", e.Image)
+}
+
+func (e *ImagePullError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ImagePullError) Is(target error) bool {
+	return target == ErrImagePull
+}
+
+// ErrorCode is a stable identifier of an error, for the API clients.
+type ErrorCode string
+
+// Codes of the sentinel errors
+const (
+	CodeAlreadyRunning       ErrorCode = "already_running"
+	CodeAlreadyStopped       ErrorCode = "already_stopped"
+	CodeTransitionInProgress ErrorCode = "transition_in_progress"
+	CodeNotFound             ErrorCode = "not_found"
+	CodeImagePullFailed      ErrorCode = "image_pull_failed"
+	CodeResourceRejected     ErrorCode = "resource_rejected"
+	// CodeInternal is the code of any other error
+	CodeInternal ErrorCode = "internal"
+)
+
+var errorCodes = []struct {
+	err  error
+	code ErrorCode
+}{
+	{ErrAlreadyRunning, CodeAlreadyRunning},
+	{ErrAlreadyStopped, CodeAlreadyStopped},
+	{ErrTransitionInProgress, CodeTransitionInProgress},
+	{ErrNotFound, CodeNotFound},
+	{ErrImagePull, CodeImagePullFailed},
+	{ErrResourceRejected, CodeResourceRejected},
+}
+
+// CodeOf returns the stable code of the error, CodeInternal if it's
+// not a known error, or an empty code if the error is nil.
+func CodeOf(err error) ErrorCode {
+	if err == nil {
+		return ""
+	}
+
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+
+	return CodeInternal
+}
@@ -0,0 +1,241 @@
+package gc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"code.cloudfoundry.org/bytefmt"
+	"github.com/PanelMc/worker"
+	"github.com/PanelMc/worker/container"
+	"github.com/PanelMc/worker/node"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+var logger = logrus.WithField("context", "gc")
+
+// Options defines what the garbage collection keeps.
+type Options struct {
+	// Images referenced besides the ones used by the servers, e.g. by the presets.
+	// The unused images of their repositories are removed.
+	Images []string
+	// Imported are the IDs of the images imported by the worker
+	Imported []string
+	// DryRun only reports what would be removed
+	DryRun bool
+}
+
+// Report is the result of a garbage collection.
+type Report struct {
+	Images     []RemovedImage     `json:"images"`
+	Containers []RemovedContainer `json:"containers"`
+	// Reclaimed is the space freed by the removed images, in bytes.
+	// Layers shared with other images are not freed, making it an upper bound.
+	Reclaimed uint64 `json:"reclaimed"`
+	DryRun    bool   `json:"dry_run"`
+}
+
+// RemovedImage is an image removed by the garbage collection.
+type RemovedImage struct {
+	ID   string   `json:"id"`
+	Tags []string `json:"tags,omitempty"`
+	Size int64    `json:"size"`
+}
+
+// RemovedContainer is an orphan container removed by the garbage collection.
+type RemovedContainer struct {
+	ID     string `json:"id"`
+	Server string `json:"server"`
+}
+
+func (r Report) String() string {
+	action := "Removed"
+	if r.DryRun {
+		action = "Would remove"
+	}
+
+	return fmt.Sprintf("%s %d images and %d containers, reclaiming %s",
+		action, len(r.Images), len(r.Containers), bytefmt.ByteSize(r.Reclaimed))
+}
+
+// Run removes the worker containers without a matching server, and the
+// images of the worker not referenced by any server, container or the options.
+func Run(ctx context.Context, cli *client.Client, opts Options) (Report, error) {
+	report := Report{DryRun: opts.DryRun}
+
+	// Remove the containers first, so their images can be removed as well
+	containers, err := collectContainers(ctx, cli, opts.DryRun)
+	report.Containers = containers
+	if err != nil {
+		return report, err
+	}
+
+	images, err := collectImages(ctx, cli, opts)
+	report.Images = images
+	for _, image := range images {
+		report.Reclaimed += uint64(image.Size)
+	}
+	if err != nil {
+		return report, err
+	}
+
+	logger.Info(report)
+	return report, nil
+}
+
+// collectContainers removes the containers labelled by the worker,
+// whose server is neither managed nor has a stored spec.
+func collectContainers(ctx context.Context, cli *client.Client, dryRun bool) ([]RemovedContainer, error) {
+	list, err := cli.ContainerList(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", container.ServerLabel)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Servers being created have a container before their spec is saved
+	pending := make(map[string]bool)
+	for _, name := range node.PendingNames() {
+		pending[name] = true
+	}
+
+	removed := make([]RemovedContainer, 0)
+	for _, c := range list {
+		server := c.Labels[container.ServerLabel]
+		if pending[server] || !orphan(server) {
+			continue
+		}
+
+		if !dryRun {
+			logger.Infof("Removing orphan container %s of server '%s'...", c.ID, server)
+			err := cli.ContainerRemove(ctx, c.ID, types.ContainerRemoveOptions{Force: true})
+			if err != nil && !client.IsErrNotFound(err) {
+				return removed, err
+			}
+		}
+
+		removed = append(removed, RemovedContainer{ID: c.ID, Server: server})
+	}
+
+	return removed, nil
+}
+
+// orphan returns whether the container doesn't belong to any server.
+func orphan(server string) bool {
+	if _, ok := worker.GetServer(server); ok {
+		return false
+	}
+
+	// Servers not loaded yet still have their spec stored
+	_, err := worker.LoadSpec(server)
+	return errors.Is(err, os.ErrNotExist)
+}
+
+// collectImages removes the images of the worker not referenced by the
+// servers, the options, the servers being created, or used by any container.
+// The images of the worker are the ones built or imported by it, and the
+// ones of the repositories referenced, which the worker pulls.
+func collectImages(ctx context.Context, cli *client.Client, opts Options) ([]RemovedImage, error) {
+	used := make(map[string]bool)
+
+	refs := append([]string{}, opts.Images...)
+	for _, s := range worker.Servers() {
+		image := s.Container().Options().Image
+		refs = append(refs, image.ID)
+		if image.Pinned != "" {
+			used[image.Pinned] = true
+		}
+	}
+	// Images being pulled or built, or pulled for servers not created yet
+	refs = append(refs, node.PendingImages()...)
+	refs = append(refs, container.PendingImages()...)
+
+	repositories := make(map[string]bool)
+	for _, ref := range refs {
+		if repository := repositoryOf(ref); repository != "" {
+			repositories[repository] = true
+		}
+	}
+
+	imported := make(map[string]bool)
+	for _, id := range opts.Imported {
+		imported[id] = true
+	}
+
+	for _, ref := range refs {
+		inspect, _, err := cli.ImageInspectWithRaw(ctx, ref)
+		if err != nil {
+			if client.IsErrNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		used[inspect.ID] = true
+	}
+
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range containers {
+		used[c.ImageID] = true
+	}
+
+	images, err := cli.ImageList(ctx, types.ImageListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].Created < images[j].Created
+	})
+
+	removed := make([]RemovedImage, 0)
+	for _, image := range images {
+		if used[image.ID] {
+			continue
+		}
+
+		owned := imported[image.ID] || image.Labels[container.BuildHashLabel] != ""
+		for _, ref := range append(append([]string{}, image.RepoTags...), image.RepoDigests...) {
+			owned = owned || repositories[repositoryOf(ref)]
+		}
+		if !owned {
+			continue
+		}
+
+		if !opts.DryRun {
+			logger.Infof("Removing unused image %s %v...", image.ID, image.RepoTags)
+			_, err := cli.ImageRemove(ctx, image.ID, types.ImageRemoveOptions{PruneChildren: true})
+			if err != nil {
+				if client.IsErrNotFound(err) {
+					continue
+				}
+				// e.g. images with child images, which are kept
+				logger.Warnf("Failed to remove image %s: %s", image.ID, err)
+				continue
+			}
+		}
+
+		removed = append(removed, RemovedImage{ID: image.ID, Tags: image.RepoTags, Size: image.Size})
+	}
+
+	return removed, nil
+}
+
+// repositoryOf returns the repository of the image reference,
+// or an empty string if it's not a named reference, e.g. an image ID.
+func repositoryOf(ref string) string {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return ""
+	}
+
+	return named.Name()
+}
@@ -0,0 +1,53 @@
+package gc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/PanelMc/worker/engine"
+)
+
+// Schedule periodically runs the garbage collection, with the options
+// returned by the given function at each run, skipping the runs while
+// the docker daemon is unhealthy. The returned function stops the schedule,
+// cancelling the running collection and waiting for it.
+func Schedule(runtime *engine.Runtime, interval time.Duration, opts func() (Options, error)) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if !runtime.Healthy() {
+				logger.Debug("Docker daemon unhealthy, skipping the garbage collection.")
+				continue
+			}
+
+			o, err := opts()
+			if err != nil {
+				logger.Errorf("Failed to run the garbage collection: %s", err)
+				continue
+			}
+
+			if _, err := Run(ctx, runtime.Client(), o); err != nil && ctx.Err() == nil {
+				logger.Errorf("Failed to run the garbage collection: %s", err)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(cancel)
+		<-stopped
+	}
+}
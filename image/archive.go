@@ -0,0 +1,237 @@
+package image
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	goio "io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/PanelMc/worker/io"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+var logger = logrus.WithField("context", "image")
+
+// ChecksumSuffix is the suffix of the checksum file written along the
+// exported tarballs, in the sha256sum format.
+const ChecksumSuffix = ".sha256"
+
+// LoadedImage is an image loaded from a tarball.
+type LoadedImage struct {
+	// ID is the image ID, i.e. the digest of its config
+	ID   string   `json:"id"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+// manifestEntry is an image in the manifest.json of a docker save tarball.
+type manifestEntry struct {
+	Config   string   `json:"Config"`
+	RepoTags []string `json:"RepoTags"`
+}
+
+// Load loads the images of a `docker save` tarball, verifying the tarball
+// checksum when a checksum file is present, and that the loaded images
+// match the IDs in the tarball manifest.
+func Load(ctx context.Context, cli *client.Client, file string) ([]LoadedImage, error) {
+	images, checksum, err := readArchive(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifyChecksum(file, checksum); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	logger.Infof("Loading images from %s...", file)
+	res, err := cli.ImageLoad(ctx, f, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", file, err)
+	}
+	defer res.Body.Close()
+
+	if err := readLoadResponse(res.Body); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", file, err)
+	}
+
+	for _, image := range images {
+		refs := image.Tags
+		if len(refs) == 0 {
+			refs = []string{image.ID}
+		}
+
+		for _, ref := range refs {
+			inspect, _, err := cli.ImageInspectWithRaw(ctx, ref)
+			if err != nil {
+				return nil, fmt.Errorf("failed to verify image %s: %w", ref, err)
+			}
+
+			if inspect.ID != image.ID {
+				return nil, fmt.Errorf("image %s loaded from %s has ID %s, expected %s", ref, file, inspect.ID, image.ID)
+			}
+		}
+
+		logger.Infof("Loaded image %s %v", image.ID, image.Tags)
+	}
+
+	return images, nil
+}
+
+// Save exports the given images into a tarball, loadable with Load or
+// `docker load`, writing its checksum file along.
+func Save(ctx context.Context, cli *client.Client, images []string, file string) error {
+	if len(images) == 0 {
+		return errors.New("no images to save")
+	}
+
+	if err := io.MkdirAll(filepath.Dir(file)); err != nil {
+		return err
+	}
+
+	logger.Infof("Saving images %v to %s...", images, file)
+	r, err := cli.ImageSave(ctx, images)
+	if err != nil {
+		return fmt.Errorf("failed to save images: %w", err)
+	}
+	defer r.Close()
+
+	tmp := file + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, io.FilePermissions())
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+	_, err = goio.Copy(goio.MultiWriter(f, hash), r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to save images: %w", err)
+	}
+
+	if err := os.Rename(tmp, file); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	return io.WriteFile(file+ChecksumSuffix, []byte(checksum+"  "+filepath.Base(file)+"\n"))
+}
+
+// readArchive reads the images in the manifest of the tarball,
+// along with the tarball checksum.
+func readArchive(file string) ([]LoadedImage, string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	r := goio.TeeReader(bufio.NewReader(f), hash)
+
+	var manifest []manifestEntry
+	found := false
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == goio.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid image tarball %s: %w", file, err)
+		}
+
+		if header.Name == "manifest.json" {
+			if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+				return nil, "", fmt.Errorf("invalid manifest in %s: %w", file, err)
+			}
+			found = true
+		}
+	}
+
+	// Hash the tarball padding as well
+	if _, err := goio.Copy(ioutil.Discard, r); err != nil {
+		return nil, "", err
+	}
+
+	if !found {
+		return nil, "", fmt.Errorf("invalid image tarball %s: missing manifest.json", file)
+	}
+
+	images := make([]LoadedImage, 0, len(manifest))
+	for _, entry := range manifest {
+		// The config is named after its digest, "<hex>.json" or "blobs/sha256/<hex>"
+		digest := strings.TrimSuffix(filepath.Base(entry.Config), ".json")
+		images = append(images, LoadedImage{
+			ID:   "sha256:" + digest,
+			Tags: entry.RepoTags,
+		})
+	}
+
+	return images, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// verifyChecksum checks the tarball checksum against its checksum file, if present.
+func verifyChecksum(file, checksum string) error {
+	content, err := ioutil.ReadFile(file + ChecksumSuffix)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	fields := strings.Fields(string(content))
+	if len(fields) == 0 {
+		return fmt.Errorf("invalid checksum file %s%s", file, ChecksumSuffix)
+	}
+
+	if !strings.EqualFold(fields[0], checksum) {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", file, fields[0], checksum)
+	}
+
+	return nil
+}
+
+// readLoadResponse reads the messages of the image load,
+// returning the error message if any.
+func readLoadResponse(r goio.Reader) error {
+	d := json.NewDecoder(r)
+	for {
+		var message struct {
+			Stream string `json:"stream"`
+			Error  string `json:"error"`
+		}
+		if err := d.Decode(&message); err != nil {
+			if err == goio.EOF {
+				return nil
+			}
+			return err
+		}
+
+		if message.Error != "" {
+			return errors.New(message.Error)
+		}
+		if s := strings.TrimSpace(message.Stream); s != "" {
+			logger.Debug(s)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package image
+
+import (
+	"sync"
+	"time"
+
+	"github.com/PanelMc/worker"
+)
+
+var updates = struct {
+	sync.Mutex
+	status map[string]worker.ImageStatus
+}{
+	status: make(map[string]worker.ImageStatus),
+}
+
+// UpdateStatus returns the last image update check of the given server,
+// and false if it was not checked yet.
+func UpdateStatus(server string) (worker.ImageStatus, bool) {
+	updates.Lock()
+	defer updates.Unlock()
+
+	status, ok := updates.status[server]
+	return status, ok
+}
+
+// UpdatesAvailable returns the image update checks of the
+// servers with an update available, by server ID.
+func UpdatesAvailable() map[string]worker.ImageStatus {
+	updates.Lock()
+	defer updates.Unlock()
+
+	available := make(map[string]worker.ImageStatus)
+	for server, status := range updates.status {
+		if status.UpdateAvailable {
+			available[server] = status
+		}
+	}
+
+	return available
+}
+
+// StartUpdateCheck periodically checks whether the image tag of
+// each server refers to a different image than the pinned one.
+// The returned function stops the check, waiting for the running one.
+func StartUpdateCheck(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				checkUpdates()
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-stopped
+	}
+}
+
+func checkUpdates() {
+	servers := worker.Servers()
+	checked := make(map[string]worker.ImageStatus, len(servers))
+	for _, s := range servers {
+		status, err := s.Container().CheckImageUpdate()
+		if err != nil {
+			logger.Warnf("Failed to check the image of server '%s': %s", s.ID(), err)
+			continue
+		}
+		checked[s.ID()] = status
+
+		previous, _ := UpdateStatus(s.ID())
+		if status.UpdateAvailable && status.Latest != previous.Latest {
+			logger.Infof("Update available for server '%s': image %s changed to %s", s.ID(), status.Image, status.Latest)
+			s.Container().Logger().Infof("Image update available: %s changed to %s", status.Image, status.Latest)
+		}
+	}
+
+	updates.Lock()
+	updates.status = checked
+	updates.Unlock()
+}
@@ -0,0 +1,161 @@
+package image
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PanelMc/worker/engine"
+	"github.com/PanelMc/worker/io"
+	"github.com/docker/docker/client"
+)
+
+// Subfolders of the watched folder where the tarballs are moved after loading
+const (
+	importedFolder = "imported"
+	failedFolder   = "failed"
+)
+
+// importedSuffix is the suffix of the file listing the images loaded
+// from a tarball, written along the imported tarballs.
+const importedSuffix = ".images.json"
+
+// WatchImports periodically loads the tarballs placed in the given folder,
+// moving them into the "imported" or "failed" subfolders after loading,
+// while the docker daemon is healthy. The returned function stops watching,
+// cancelling the running import and waiting for it.
+func WatchImports(runtime *engine.Runtime, folder string, interval time.Duration) (stop func(), err error) {
+	if err := io.MkdirAll(folder); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if runtime.Healthy() {
+				importFolder(ctx, runtime.Client(), folder)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(cancel)
+		<-stopped
+	}, nil
+}
+
+func importFolder(ctx context.Context, cli *client.Client, folder string) {
+	files, err := ioutil.ReadDir(folder)
+	if err != nil {
+		logger.Errorf("Failed to read the image import folder: %s", err)
+		return
+	}
+
+	for _, info := range files {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), ".tar") {
+			continue
+		}
+
+		// Skip the tarballs still being written
+		if time.Since(info.ModTime()) < 5*time.Second {
+			continue
+		}
+
+		file := filepath.Join(folder, info.Name())
+		target := importedFolder
+		images, err := Load(ctx, cli, file)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			logger.Errorf("Failed to import %s: %s", file, err)
+			target = failedFolder
+		}
+
+		if err := moveTarball(file, filepath.Join(folder, target)); err != nil {
+			logger.Errorf("Failed to move %s: %s", file, err)
+		}
+
+		if target == importedFolder {
+			if err := writeImported(filepath.Join(folder, target, info.Name()), images); err != nil {
+				logger.Errorf("Failed to record the images imported from %s: %s", file, err)
+			}
+		}
+	}
+}
+
+// writeImported records the images loaded from the given tarball.
+func writeImported(file string, images []LoadedImage) error {
+	b, err := json.Marshal(images)
+	if err != nil {
+		return err
+	}
+
+	return io.WriteFile(file+importedSuffix, b)
+}
+
+// ImportedImages returns the IDs of the images imported
+// from the tarballs placed in the given folder.
+func ImportedImages(folder string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(folder, importedFolder, "*"+importedSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(files))
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		var images []LoadedImage
+		if err := json.Unmarshal(b, &images); err != nil {
+			return nil, fmt.Errorf("invalid imported images file %s: %w", file, err)
+		}
+
+		for _, image := range images {
+			ids = append(ids, image.ID)
+		}
+	}
+
+	return ids, nil
+}
+
+// moveTarball moves the tarball, and its checksum file if present, into the given folder.
+func moveTarball(file, folder string) error {
+	if err := io.MkdirAll(folder); err != nil {
+		return err
+	}
+
+	if err := os.Rename(file, filepath.Join(folder, filepath.Base(file))); err != nil {
+		return err
+	}
+
+	checksum := file + ChecksumSuffix
+	if err := os.Rename(checksum, filepath.Join(folder, filepath.Base(checksum))); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package infra
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/bytefmt"
+	"github.com/PanelMc/worker/node"
+)
+
+// InitializeAdmission sets the admission policy based on the provided config
+func InitializeAdmission(cfg Config) error {
+	if cfg.Admission == nil {
+		return nil
+	}
+
+	policy := node.AdmissionPolicy{
+		MemoryOvercommit: cfg.Admission.MemoryOvercommit,
+		SwapOvercommit:   cfg.Admission.SwapOvercommit,
+		MaxServers:       cfg.Admission.MaxServers,
+	}
+
+	var err error
+	if cfg.Admission.ReservedMemory != "" {
+		if policy.ReservedMemory, err = bytefmt.ToBytes(cfg.Admission.ReservedMemory); err != nil {
+			return fmt.Errorf("invalid admission reserved_memory '%s': %w", cfg.Admission.ReservedMemory, err)
+		}
+	}
+
+	if cfg.Admission.MinFreeDisk != "" {
+		if policy.MinFreeDisk, err = bytefmt.ToBytes(cfg.Admission.MinFreeDisk); err != nil {
+			return fmt.Errorf("invalid admission min_free_disk '%s': %w", cfg.Admission.MinFreeDisk, err)
+		}
+	}
+
+	node.SetAdmissionPolicy(policy)
+	return nil
+}
@@ -0,0 +1,45 @@
+package infra
+
+import (
+	"fmt"
+
+	"github.com/PanelMc/worker"
+	"github.com/PanelMc/worker/alert"
+	"github.com/sirupsen/logrus"
+)
+
+// InitializeAlerts creates the alert engine based on the provided config
+func InitializeAlerts(cfg Config) (*alert.Engine, error) {
+	notifiers := make(map[string]alert.Notifier)
+	for _, n := range cfg.Notifiers {
+		switch n.Type {
+		case "webhook":
+			if n.URL == "" {
+				return nil, fmt.Errorf("notifier '%s' requires an url", n.Name)
+			}
+
+			notifiers[n.Name] = alert.NewWebhookNotifier(n.URL)
+		case "log":
+			notifiers[n.Name] = alert.LogNotifier
+		case "console":
+			notifiers[n.Name] = alert.ConsoleNotifier
+		default:
+			return nil, fmt.Errorf("notifier '%s' has unknown type '%s'", n.Name, n.Type)
+		}
+	}
+
+	engine, err := alert.NewEngine(cfg.Alerts, notifiers)
+	if err != nil {
+		return nil, err
+	}
+
+	// Evaluate the global and preset rules of every server managed by the worker
+	worker.OnServerRegistered(func(s worker.Server) {
+		if err := engine.Watch(s.ID(), s.Container(), s.Container().Options().Alerts...); err != nil {
+			logrus.Errorf("Failed to watch the alerts of server '%s': %s", s.ID(), err)
+		}
+	})
+	worker.OnServerRemoved(engine.Unwatch)
+
+	return engine, nil
+}
@@ -0,0 +1,20 @@
+package infra
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/PanelMc/worker"
+)
+
+// InitializeBindBasePaths restricts the bind host directories based on the provided config
+func InitializeBindBasePaths(cfg Config) error {
+	for _, p := range cfg.BindBasePaths {
+		if !filepath.IsAbs(p) {
+			return fmt.Errorf("invalid bind base path '%s': must be an absolute path", p)
+		}
+	}
+
+	worker.SetBindBasePaths(cfg.BindBasePaths)
+	return nil
+}
@@ -1,7 +1,9 @@
 package infra
 
 import (
+	"fmt"
 	"os"
+	"time"
 
 	"github.com/PanelMc/worker"
 	"github.com/PanelMc/worker/io"
@@ -40,6 +42,7 @@ func InitializeConfig() (cfg Config, err error) {
 				},
 			},
 			PresetsFolder:     "./presets/",
+			ServersFolder:     "./servers/",
 			FilePermissions:   644,
 			FolderPermissions: 744,
 		}, "config.hcl")
@@ -57,22 +60,79 @@ func InitializeConfig() (cfg Config, err error) {
 		}
 	}
 
+	if c.ServersFolder == "" {
+		c.ServersFolder = "./servers/"
+	}
+
+	imageUpdateInterval := time.Hour
+	if c.ImageUpdateInterval != "" {
+		imageUpdateInterval, err = time.ParseDuration(c.ImageUpdateInterval)
+		if err != nil {
+			err = fmt.Errorf("invalid image update interval '%s': %w", c.ImageUpdateInterval, err)
+			return
+		}
+	}
+
+	var gcInterval time.Duration
+	if c.GCInterval != "" {
+		gcInterval, err = time.ParseDuration(c.GCInterval)
+		if err != nil {
+			err = fmt.Errorf("invalid gc interval '%s': %w", c.GCInterval, err)
+			return
+		}
+	}
+
+	dockerHealthInterval := 30 * time.Second
+	if c.DockerHealthInterval != "" {
+		dockerHealthInterval, err = time.ParseDuration(c.DockerHealthInterval)
+		if err != nil {
+			err = fmt.Errorf("invalid docker health interval '%s': %w", c.DockerHealthInterval, err)
+			return
+		}
+	}
+
+	diskScanInterval := 5 * time.Minute
+	if c.DiskScanInterval != "" {
+		diskScanInterval, err = time.ParseDuration(c.DiskScanInterval)
+		if err != nil {
+			err = fmt.Errorf("invalid disk scan interval '%s': %w", c.DiskScanInterval, err)
+			return
+		}
+	}
+
 	cfg = Config{
 		Server: serverConfig,
 
+		Alerts:    c.Alerts,
+		Notifiers: c.Notifiers,
+		Admission: c.Admission,
+		PortPools: c.PortPools,
+
+		BindBasePaths: c.BindBasePaths,
+
+		DockerHealthInterval: dockerHealthInterval,
+
+		DiskScanInterval: diskScanInterval,
+		UsernsRemapUser:  c.UsernsRemapUser,
+
+		Registries:   c.Registries,
+		DockerConfig: c.DockerConfig,
+
+		ImageImportFolder:   c.ImageImportFolder,
+		ImageUpdateInterval: imageUpdateInterval,
+
+		GCInterval: gcInterval,
+		GCDryRun:   c.GCDryRun,
+
 		PresetsFolder:     c.PresetsFolder,
+		ServersFolder:     c.ServersFolder,
 		FilePermissions:   c.FilePermissions,
 		FolderPermissions: c.FolderPermissions,
 	}
 
 	if serverConfig != nil {
 		// Map the serverConfig
-		for i, bind := range c.Server.Binds {
-			cfg.Server.Binds[i] = worker.ContainerBind{
-				HostDir: bind.HostDir,
-				Volume:  bind.Volume,
-			}
-		}
+		copy(cfg.Server.Binds, c.Server.Binds)
 	}
 
 	return
@@ -85,8 +145,30 @@ type config struct {
 	} `hcl:"server,block"`
 
 	PresetsFolder     string      `hcl:"presets_folder"`
+	ServersFolder     string      `hcl:"servers_folder,optional"`
 	FilePermissions   os.FileMode `hcl:"file_permissions"`
 	FolderPermissions os.FileMode `hcl:"folder_permissions"`
+
+	Alerts    []worker.AlertRule `hcl:"alert,block"`
+	Notifiers []NotifierConfig   `hcl:"notifier,block"`
+	Admission *AdmissionConfig   `hcl:"admission,block"`
+	PortPools []PortPoolConfig   `hcl:"port_pool,block"`
+
+	BindBasePaths []string `hcl:"bind_base_paths,optional"`
+
+	DockerHealthInterval string `hcl:"docker_health_interval,optional"`
+
+	DiskScanInterval string `hcl:"disk_scan_interval,optional"`
+	UsernsRemapUser  string `hcl:"userns_remap_user,optional"`
+
+	Registries   []RegistryConfig `hcl:"registry,block"`
+	DockerConfig string           `hcl:"docker_config,optional"`
+
+	ImageImportFolder   string `hcl:"image_import_folder,optional"`
+	ImageUpdateInterval string `hcl:"image_update_interval,optional"`
+
+	GCInterval string `hcl:"gc_interval,optional"`
+	GCDryRun   bool   `hcl:"gc_dry_run,optional"`
 }
 
 // Config defines how the worker should run
@@ -96,10 +178,50 @@ type Config struct {
 
 	// PresetsFolder defines the folder to be used for server preset files.
 	PresetsFolder string
+	// ServersFolder defines the folder where the servers specification is stored.
+	ServersFolder string
 	// Permission used when creating a new file. e.g. configuration files
 	FilePermissions os.FileMode
 	// Permission used when creating a new folder
 	FolderPermissions os.FileMode
+
+	// Alerts defines the alert rules evaluated for every server.
+	Alerts []worker.AlertRule
+	// Notifiers defines the notifiers available to the alert rules,
+	// besides the builtin "log" and "console" ones.
+	Notifiers []NotifierConfig
+	// Admission defines the checks made before creating a new server.
+	Admission *AdmissionConfig
+	// PortPools defines the ports available for automatic allocation.
+	PortPools []PortPoolConfig
+	// BindBasePaths restricts the bind host directories to be inside these paths.
+	BindBasePaths []string
+	// DockerHealthInterval defines how often the docker daemon is checked,
+	// reconnecting to it when it stops responding.
+	DockerHealthInterval time.Duration
+	// DiskScanInterval defines how often the disk usage of the servers is scanned.
+	DiskScanInterval time.Duration
+	// UsernsRemapUser is the "userns-remap" user of the docker daemon,
+	// used to map the bind directories owner. Defaults to "dockremap".
+	UsernsRemapUser string
+
+	// Registries defines the credentials of private registries.
+	Registries []RegistryConfig
+	// DockerConfig is the path of a docker config.json file
+	// to read registry credentials from.
+	DockerConfig string
+
+	// ImageImportFolder defines the folder watched for image tarballs to load.
+	ImageImportFolder string
+	// ImageUpdateInterval defines how often the servers are checked for image updates,
+	// 0 disables the check.
+	ImageUpdateInterval time.Duration
+
+	// GCInterval defines how often the unused images and orphan containers
+	// are removed, 0 disables the scheduled garbage collection.
+	GCInterval time.Duration
+	// GCDryRun only reports what the garbage collection would remove.
+	GCDryRun bool
 }
 
 // ServerConfig defines default configuration for new servers created
@@ -115,3 +237,66 @@ type ServerBindConfig struct {
 	// Volume defines the volume to be binded.
 	Volume string
 }
+
+// NotifierConfig defines a notifier to be used by the alert rules.
+type NotifierConfig struct {
+	// Name is the name used by the alert rules to reference this notifier.
+	Name string `hcl:"name,label"`
+	// Type defines the kind of notifier, e.g. "webhook".
+	Type string `hcl:"type"`
+	// URL defines where to send the alerts, used by the webhook notifier.
+	URL string `hcl:"url,optional"`
+}
+
+// AdmissionConfig defines the checks made before creating a new server.
+// Omitted values disable the respective check.
+type AdmissionConfig struct {
+	// MemoryOvercommit is the ratio of the host memory that can be allocated
+	// to servers, e.g. 1.5 allows allocating 150% of the memory.
+	MemoryOvercommit float64 `hcl:"memory_overcommit,optional"`
+	// SwapOvercommit is the ratio of the host swap that can be allocated to servers.
+	SwapOvercommit float64 `hcl:"swap_overcommit,optional"`
+	// ReservedMemory is the memory reserved for the host, e.g. "1GB".
+	ReservedMemory string `hcl:"reserved_memory,optional"`
+	// MaxServers is the maximum number of servers in this node.
+	MaxServers int `hcl:"max_servers,optional"`
+	// MinFreeDisk is the minimum free space required on the data filesystems, e.g. "10GB".
+	MinFreeDisk string `hcl:"min_free_disk,optional"`
+}
+
+// PortPoolConfig defines ports available for automatic allocation.
+type PortPoolConfig struct {
+	// Address is the host IP address to bind the ports to, e.g. "0.0.0.0"
+	Address string `hcl:"address,label"`
+	// Ranges of ports available, e.g. ["25565-25665"]
+	Ranges []string `hcl:"ranges"`
+}
+
+// RegistryConfig defines the credentials of a docker registry.
+type RegistryConfig struct {
+	// Host is the registry host, e.g. "ghcr.io"
+	Host     string `hcl:"host,label"`
+	Username string `hcl:"username,optional"`
+	Password string `hcl:"password,optional"`
+	// IdentityToken is used instead of the username and password, if set
+	IdentityToken string `hcl:"identity_token,optional"`
+}
+
+// String returns the registry config with the credentials redacted.
+func (r RegistryConfig) String() string {
+	return r.GoString()
+}
+
+// GoString returns the registry config with the credentials redacted,
+// used when printing the worker config.
+func (r RegistryConfig) GoString() string {
+	return fmt.Sprintf("infra.RegistryConfig{Host:%q, Username:%q, Password:%q, IdentityToken:%q}",
+		r.Host, r.Username, redact(r.Password), redact(r.IdentityToken))
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "REDACTED"
+}
@@ -0,0 +1,11 @@
+package infra
+
+import (
+	"github.com/PanelMc/worker/node"
+)
+
+// InitializeDiskMonitor starts scanning the disk usage of the servers
+// based on the provided config. The returned function stops the monitor.
+func InitializeDiskMonitor(cfg Config) (stop func()) {
+	return node.StartDiskMonitor(cfg.DiskScanInterval)
+}
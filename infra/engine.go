@@ -0,0 +1,23 @@
+package infra
+
+import (
+	"context"
+
+	"github.com/PanelMc/worker/engine"
+)
+
+// InitializeRuntime connects to the docker daemon shared by the worker,
+// checking its health based on the provided config.
+func InitializeRuntime(cfg Config) (*engine.Runtime, error) {
+	runtime, err := engine.Connect(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	engine.SetDefault(runtime)
+	if cfg.DockerHealthInterval > 0 {
+		runtime.StartHealthCheck(cfg.DockerHealthInterval)
+	}
+
+	return runtime, nil
+}
@@ -0,0 +1,15 @@
+package infra
+
+import (
+	"github.com/PanelMc/worker/image"
+)
+
+// InitializeImageUpdateCheck starts checking the servers for image updates
+// based on the provided config. The returned function stops the check.
+func InitializeImageUpdateCheck(cfg Config) (stop func()) {
+	if cfg.ImageUpdateInterval <= 0 {
+		return func() {}
+	}
+
+	return image.StartUpdateCheck(cfg.ImageUpdateInterval)
+}
@@ -0,0 +1,25 @@
+package infra
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/PanelMc/worker/io"
+)
+
+// InitializePermissions sets the permissions used for every file
+// and folder created by the worker
+func InitializePermissions(cfg Config) {
+	io.SetPermissions(octalMode(cfg.FilePermissions), octalMode(cfg.FolderPermissions))
+}
+
+// octalMode interprets the digits of the configured permissions as octal,
+// e.g. 644 as 0644, as it's how they are written in the config file.
+func octalMode(mode os.FileMode) os.FileMode {
+	m, err := strconv.ParseUint(strconv.FormatUint(uint64(mode), 10), 8, 32)
+	if err != nil {
+		return mode
+	}
+
+	return os.FileMode(m)
+}
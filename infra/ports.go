@@ -0,0 +1,35 @@
+package infra
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/PanelMc/worker"
+	"github.com/PanelMc/worker/node"
+)
+
+// InitializePortPool sets the port pool based on the provided config
+func InitializePortPool(cfg Config) error {
+	var pool node.PortPool
+	for _, p := range cfg.PortPools {
+		if net.ParseIP(p.Address) == nil {
+			return fmt.Errorf("invalid port pool address '%s'", p.Address)
+		}
+
+		for _, r := range p.Ranges {
+			start, end, err := worker.ParsePortRange(r)
+			if err != nil {
+				return fmt.Errorf("invalid port pool range '%s': %w", r, err)
+			}
+
+			pool.Ranges = append(pool.Ranges, node.PortRange{
+				HostIP: p.Address,
+				Start:  start,
+				End:    end,
+			})
+		}
+	}
+
+	node.SetPortPool(pool)
+	return nil
+}
@@ -0,0 +1,33 @@
+package infra
+
+import (
+	"github.com/PanelMc/worker"
+	"github.com/PanelMc/worker/io"
+)
+
+// InitializeRegistries sets the registry credentials based on the provided config.
+// The registries in the config take precedence over the docker config ones.
+func InitializeRegistries(cfg Config) error {
+	auths := make(map[string]worker.RegistryAuth)
+	if cfg.DockerConfig != "" {
+		dockerAuths, err := io.LoadDockerConfig(cfg.DockerConfig)
+		if err != nil {
+			return err
+		}
+
+		for host, auth := range dockerAuths {
+			auths[host] = auth
+		}
+	}
+
+	for _, r := range cfg.Registries {
+		auths[worker.NormalizeRegistryHost(r.Host)] = worker.RegistryAuth{
+			Username:      r.Username,
+			Password:      r.Password,
+			IdentityToken: r.IdentityToken,
+		}
+	}
+
+	worker.SetRegistryAuths(auths)
+	return nil
+}
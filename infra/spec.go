@@ -0,0 +1,13 @@
+package infra
+
+import (
+	"github.com/PanelMc/worker"
+	"github.com/PanelMc/worker/io"
+)
+
+// InitializeSpecStore sets the store used to persist the servers specification
+func InitializeSpecStore(cfg Config) {
+	worker.SetSpecStore(&io.FileSpecStore{
+		Folder: cfg.ServersFolder,
+	})
+}
@@ -0,0 +1,10 @@
+package infra
+
+import (
+	"github.com/PanelMc/worker/node"
+)
+
+// InitializeUserns sets the user namespace remapping user based on the provided config
+func InitializeUserns(cfg Config) {
+	node.SetUsernsRemapUser(cfg.UsernsRemapUser)
+}
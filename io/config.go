@@ -3,8 +3,6 @@ package io
 import (
 	"fmt"
 	"io/ioutil"
-	"os"
-	"path/filepath"
 	"strings"
 )
 
@@ -27,11 +25,7 @@ func SaveConfig(cfg interface{}, file string) (err error) {
 
 	file = validateFileName(file)
 
-	if err = os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
-		return
-	}
-
-	err = ioutil.WriteFile(file, hclEncode(cfg), 0777)
+	err = WriteFile(file, hclEncode(cfg))
 	return
 }
 
@@ -0,0 +1,61 @@
+package io
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/PanelMc/worker"
+)
+
+type dockerConfig struct {
+	Auths map[string]struct {
+		Auth          string `json:"auth"`
+		Username      string `json:"username"`
+		Password      string `json:"password"`
+		IdentityToken string `json:"identitytoken"`
+	} `json:"auths"`
+}
+
+// LoadDockerConfig reads the registry credentials stored in a docker
+// config.json file, by registry host. Credential helpers are not supported.
+func LoadDockerConfig(file string) (map[string]worker.RegistryAuth, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var config dockerConfig
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("invalid docker config %s: %w", file, err)
+	}
+
+	auths := make(map[string]worker.RegistryAuth, len(config.Auths))
+	for host, entry := range config.Auths {
+		auth := worker.RegistryAuth{
+			Username:      entry.Username,
+			Password:      entry.Password,
+			IdentityToken: entry.IdentityToken,
+		}
+
+		// The "auth" field is the base64 encoded "username:password"
+		if entry.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("invalid auth for registry '%s' in %s: %w", host, file, err)
+			}
+
+			parts := strings.SplitN(string(decoded), ":", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("invalid auth for registry '%s' in %s", host, file)
+			}
+			auth.Username, auth.Password = parts[0], parts[1]
+		}
+
+		auths[worker.NormalizeRegistryHost(host)] = auth
+	}
+
+	return auths, nil
+}
@@ -0,0 +1,113 @@
+package io
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var permissions = struct {
+	sync.RWMutex
+	file   os.FileMode
+	folder os.FileMode
+}{
+	file:   0644,
+	folder: 0755,
+}
+
+// SetPermissions sets the permissions used when creating files and folders
+func SetPermissions(file, folder os.FileMode) {
+	permissions.Lock()
+	defer permissions.Unlock()
+
+	permissions.file = file
+	permissions.folder = folder
+}
+
+// FilePermissions returns the permissions used when creating files
+func FilePermissions() os.FileMode {
+	permissions.RLock()
+	defer permissions.RUnlock()
+
+	return permissions.file
+}
+
+// FolderPermissions returns the permissions used when creating folders
+func FolderPermissions() os.FileMode {
+	permissions.RLock()
+	defer permissions.RUnlock()
+
+	return permissions.folder
+}
+
+// MkdirAll creates the folder, along with any missing parents,
+// using the configured folder permissions
+func MkdirAll(folder string) error {
+	return os.MkdirAll(folder, FolderPermissions())
+}
+
+// WriteFile writes the file using the configured file permissions,
+// creating the parent folders if missing. The content is written to a
+// temporary file first, so a crash doesn't leave the file corrupted.
+func WriteFile(file string, content []byte) error {
+	if err := MkdirAll(filepath.Dir(file)); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(file+".tmp", content, FilePermissions()); err != nil {
+		return err
+	}
+
+	// WriteFile permissions are affected by the umask
+	if err := os.Chmod(file+".tmp", FilePermissions()); err != nil {
+		return err
+	}
+
+	return os.Rename(file+".tmp", file)
+}
+
+// ProvisionDir creates the folder, if missing, using the configured folder
+// permissions, and sets its owner. Existing folders are left unchanged, and
+// missing parents are created without changing their owner, as they may be
+// shared by other servers. Negative ids are left unchanged.
+func ProvisionDir(folder string, uid, gid int) error {
+	folder = filepath.Clean(folder)
+
+	if info, err := os.Stat(folder); err == nil {
+		if !info.IsDir() {
+			return fmt.Errorf("'%s' is not a directory", folder)
+		}
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := MkdirAll(filepath.Dir(folder)); err != nil {
+		return err
+	}
+
+	if err := os.Mkdir(folder, FolderPermissions()); err != nil {
+		if os.IsExist(err) {
+			// Created concurrently, e.g. by a server sharing it
+			return nil
+		}
+		return err
+	}
+
+	// Mkdir permissions are affected by the umask
+	if err := os.Chmod(folder, FolderPermissions()); err != nil {
+		return err
+	}
+
+	if uid < 0 && gid < 0 {
+		return nil
+	}
+
+	if err := os.Chown(folder, uid, gid); err != nil {
+		return fmt.Errorf("failed to set the owner of '%s' to %d:%d: %w", folder, uid, gid, err)
+	}
+
+	return nil
+}
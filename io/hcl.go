@@ -1,9 +1,15 @@
 package io
 
 import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
 	"github.com/hashicorp/hcl/v2/gohcl"
 	"github.com/hashicorp/hcl/v2/hclsimple"
 	"github.com/hashicorp/hcl/v2/hclwrite"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
 )
 
 func hclEncode(cfg interface{}) []byte {
@@ -11,10 +17,62 @@ func hclEncode(cfg interface{}) []byte {
 
 	f := hclwrite.NewEmptyFile()
 	*f.Body() = *block.Body()
+	removeEmptyAttributes(f.Body())
 
 	return f.Bytes()
 }
 
+// removeEmptyAttributes removes the null and empty string attributes,
+// which are the unset optional values, to keep the file readable.
+func removeEmptyAttributes(body *hclwrite.Body) {
+	for name, attr := range body.Attributes() {
+		value := strings.TrimSpace(string(attr.Expr().BuildTokens(nil).Bytes()))
+		if value == "null" || value == `""` {
+			body.RemoveAttribute(name)
+		}
+	}
+
+	for _, block := range body.Blocks() {
+		removeEmptyAttributes(block.Body())
+	}
+}
+
 func hclDecode(src []byte, cfg interface{}) error {
 	return hclsimple.Decode("TODO.hcl", src, nil, cfg)
 }
+
+// EncodeJSONAsHCL encodes the json representation of the given
+// value as HCL attributes, e.g. for values without hcl tags.
+func EncodeJSONAsHCL(v interface{}) ([]byte, error) {
+	src, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := ctyjson.ImpliedType(src)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := ctyjson.Unmarshal(src, t)
+	if err != nil {
+		return nil, err
+	}
+	if !value.Type().IsObjectType() {
+		return nil, fmt.Errorf("can't encode %s as HCL attributes", value.Type().FriendlyName())
+	}
+
+	f := hclwrite.NewEmptyFile()
+	names := make([]string, 0)
+	for name := range value.Type().AttributeTypes() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f.Body().SetAttributeValue(name, value.GetAttr(name))
+	}
+	removeEmptyAttributes(f.Body())
+
+	return f.Bytes(), nil
+}
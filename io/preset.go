@@ -0,0 +1,39 @@
+package io
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/PanelMc/worker"
+)
+
+// LoadPresets loads the presets in the given folder, by file name
+// without the ".hcl" extension, failing if any of them is invalid.
+func LoadPresets(folder string) (map[string]worker.ServerPreset, error) {
+	files, err := ioutil.ReadDir(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	presets := make(map[string]worker.ServerPreset)
+	for _, info := range files {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), ".hcl") {
+			continue
+		}
+
+		var preset worker.ServerPreset
+		if err := LoadConfig(filepath.Join(folder, info.Name()), &preset); err != nil {
+			return nil, fmt.Errorf("invalid preset %s: %w", info.Name(), err)
+		}
+
+		if err := preset.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid preset %s: %w", info.Name(), err)
+		}
+
+		presets[strings.TrimSuffix(info.Name(), ".hcl")] = preset
+	}
+
+	return presets, nil
+}
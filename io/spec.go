@@ -0,0 +1,67 @@
+package io
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/PanelMc/worker"
+)
+
+// FileSpecStore persists the container options as json
+// files inside a folder.
+type FileSpecStore struct {
+	Folder string
+}
+
+// SaveSpec saves the options into the file named after the container
+func (s *FileSpecStore) SaveSpec(opts worker.ContainerOptions) error {
+	content, err := json.MarshalIndent(opts, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return WriteFile(s.file(opts.ContainerName), content)
+}
+
+// LoadSpec loads the options from the file named after the container
+func (s *FileSpecStore) LoadSpec(name string) (opts worker.ContainerOptions, err error) {
+	content, err := ioutil.ReadFile(s.file(name))
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(content, &opts)
+	return
+}
+
+// DeleteSpec deletes the file named after the container
+func (s *FileSpecStore) DeleteSpec(name string) error {
+	err := os.Remove(s.file(name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// ListSpecs returns the names of the containers with a spec file
+func (s *FileSpecStore) ListSpecs() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(s.Folder, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, strings.TrimSuffix(filepath.Base(file), ".json"))
+	}
+
+	return names, nil
+}
+
+func (s *FileSpecStore) file(name string) string {
+	return filepath.Join(s.Folder, filepath.Base(name)+".json")
+}
@@ -0,0 +1,224 @@
+package worker
+
+import (
+	"fmt"
+	"strings"
+
+	"code.cloudfoundry.org/bytefmt"
+)
+
+// ContainerJVM defines how to generate the JVM flags of java servers,
+// based on the memory limit of the container.
+type ContainerJVM struct {
+	// HeapPercentage is the percentage of the memory limit, after
+	// subtracting the reserved memory, to use as heap. Defaults to 85.
+	HeapPercentage float64 `hcl:"heap_percentage,optional" json:"heap_percentage,omitempty"`
+	// Reserved is the memory reserved for the JVM overhead, e.g. "256MB"
+	Reserved string `hcl:"reserved,optional" json:"reserved,omitempty"`
+	// GCProfile defines the garbage collector flags to use.
+	// Can be "aikar", "g1" or "none". Defaults to "none".
+	GCProfile string `hcl:"gc_profile,optional" json:"gc_profile,omitempty"`
+	// Flags defines extra flags to append
+	Flags []string `hcl:"flags,optional" json:"flags,omitempty"`
+	// Inject defines how the flags are passed to the server, can be
+	// "env" or "command". Defaults to "env".
+	Inject string `hcl:"inject,optional" json:"inject,omitempty"`
+	// Env is the environment variable used to inject the flags.
+	// Defaults to "JVM_OPTS", as used by the itzg/minecraft-server image.
+	Env string `hcl:"env,optional" json:"env,omitempty"`
+	// Command is the startup command used to inject the flags,
+	// where the "{jvm_flags}" argument is replaced by the flags.
+	Command []string `hcl:"command,optional" json:"command,omitempty"`
+	// Applied are the flags the container was created with, which differ
+	// from the generated ones after a resize, until it's recreated
+	Applied []string `json:"applied,omitempty"`
+}
+
+const (
+	// JVMInjectEnv injects the JVM flags with an environment variable.
+	JVMInjectEnv = "env"
+	// JVMInjectCommand injects the JVM flags in the startup command.
+	JVMInjectCommand = "command"
+)
+
+// JVMFlagsPlaceholder is the Command argument replaced by the JVM flags.
+const JVMFlagsPlaceholder = "{jvm_flags}"
+
+var gcProfiles = map[string]func(heap uint64) []string{
+	"none": func(heap uint64) []string { return nil },
+	"g1":   func(heap uint64) []string { return []string{"-XX:+UseG1GC"} },
+	// https://mcflags.emc.gs
+	"aikar": func(heap uint64) []string {
+		newSize, maxNewSize, regionSize, reserve, occupancy := 30, 40, "8M", 20, 15
+		if heap >= 12*bytefmt.GIGABYTE {
+			newSize, maxNewSize, regionSize, reserve, occupancy = 40, 50, "16M", 15, 20
+		}
+
+		return []string{
+			"-XX:+UseG1GC",
+			"-XX:+ParallelRefProcEnabled",
+			"-XX:MaxGCPauseMillis=200",
+			"-XX:+UnlockExperimentalVMOptions",
+			"-XX:+DisableExplicitGC",
+			"-XX:+AlwaysPreTouch",
+			fmt.Sprintf("-XX:G1NewSizePercent=%d", newSize),
+			fmt.Sprintf("-XX:G1MaxNewSizePercent=%d", maxNewSize),
+			"-XX:G1HeapRegionSize=" + regionSize,
+			fmt.Sprintf("-XX:G1ReservePercent=%d", reserve),
+			"-XX:G1HeapWastePercent=5",
+			"-XX:G1MixedGCCountTarget=4",
+			fmt.Sprintf("-XX:InitiatingHeapOccupancyPercent=%d", occupancy),
+			"-XX:G1MixedGCLiveThresholdPercent=90",
+			"-XX:G1RSetUpdatingPauseTimePercent=5",
+			"-XX:SurvivorRatio=32",
+			"-XX:+PerfDisableSharedMem",
+			"-XX:MaxTenuringThreshold=1",
+			"-Dusing.aikars.flags=https://mcflags.emc.gs",
+			"-Daikars.new.flags=true",
+		}
+	},
+}
+
+// Validate checks whether the JVM settings are valid.
+func (j ContainerJVM) Validate() error {
+	if j.HeapPercentage < 0 || j.HeapPercentage > 100 {
+		return fmt.Errorf("invalid jvm heap percentage %g: must be between 0 and 100", j.HeapPercentage)
+	}
+
+	if j.Reserved != "" {
+		if _, err := bytefmt.ToBytes(j.Reserved); err != nil {
+			return fmt.Errorf("invalid jvm reserved memory '%s': %w", j.Reserved, err)
+		}
+	}
+
+	if _, ok := gcProfiles[j.gcProfile()]; !ok {
+		return fmt.Errorf("invalid jvm gc profile '%s': expected one of aikar, g1 or none", j.GCProfile)
+	}
+
+	switch j.inject() {
+	case JVMInjectEnv:
+	case JVMInjectCommand:
+		var found bool
+		for _, arg := range j.Command {
+			found = found || arg == JVMFlagsPlaceholder
+		}
+
+		if !found {
+			return fmt.Errorf("invalid jvm command: missing the '%s' argument", JVMFlagsPlaceholder)
+		}
+	default:
+		return fmt.Errorf("invalid jvm inject '%s': expected env or command", j.Inject)
+	}
+
+	return nil
+}
+
+// Heap returns the heap size, in bytes, for the given memory limit.
+func (j ContainerJVM) Heap(memoryLimit string) (uint64, error) {
+	limit, err := bytefmt.ToBytes(memoryLimit)
+	if err != nil {
+		return 0, fmt.Errorf("invalid memory limit '%s': %w", memoryLimit, err)
+	}
+
+	var reserved uint64
+	if j.Reserved != "" {
+		if reserved, err = bytefmt.ToBytes(j.Reserved); err != nil {
+			return 0, fmt.Errorf("invalid jvm reserved memory '%s': %w", j.Reserved, err)
+		}
+	}
+
+	if reserved >= limit {
+		return 0, fmt.Errorf("jvm reserved memory '%s' must be lower than the memory limit '%s'", j.Reserved, memoryLimit)
+	}
+
+	percentage := j.HeapPercentage
+	if percentage == 0 {
+		percentage = 85
+	}
+
+	return uint64(float64(limit-reserved) * percentage / 100), nil
+}
+
+// Args returns the JVM flags for the given memory limit.
+func (j ContainerJVM) Args(memoryLimit string) ([]string, error) {
+	heap, err := j.Heap(memoryLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, ok := gcProfiles[j.gcProfile()]
+	if !ok {
+		return nil, fmt.Errorf("invalid jvm gc profile '%s'", j.GCProfile)
+	}
+
+	// Use the same value for both, as recommended for game servers
+	heapMB := heap / bytefmt.MEGABYTE
+	args := []string{
+		fmt.Sprintf("-Xms%dM", heapMB),
+		fmt.Sprintf("-Xmx%dM", heapMB),
+	}
+	args = append(args, profile(heap)...)
+	args = append(args, j.Flags...)
+
+	return args, nil
+}
+
+// Apply returns the environment and startup command with the JVM flags
+// for the given memory limit injected.
+// Stale returns whether the flags generated for the memory limit differ
+// from the ones the container was created with, if known.
+func (j ContainerJVM) Stale(memoryLimit string) bool {
+	if j.Applied == nil {
+		return false
+	}
+
+	args, err := j.Args(memoryLimit)
+	if err != nil {
+		return false
+	}
+
+	return strings.Join(args, " ") != strings.Join(j.Applied, " ")
+}
+
+func (j ContainerJVM) Apply(memoryLimit string, env []string) ([]string, []string, error) {
+	args, err := j.Args(memoryLimit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if j.inject() == JVMInjectEnv {
+		name := j.Env
+		if name == "" {
+			name = "JVM_OPTS"
+		}
+
+		return append(env[:len(env):len(env)], name+"="+strings.Join(args, " ")), nil, nil
+	}
+
+	cmd := make([]string, 0, len(j.Command)+len(args))
+	for _, arg := range j.Command {
+		if arg == JVMFlagsPlaceholder {
+			cmd = append(cmd, args...)
+		} else {
+			cmd = append(cmd, arg)
+		}
+	}
+
+	return env, cmd, nil
+}
+
+func (j ContainerJVM) gcProfile() string {
+	if j.GCProfile == "" {
+		return "none"
+	}
+
+	return strings.ToLower(j.GCProfile)
+}
+
+func (j ContainerJVM) inject() string {
+	if j.Inject == "" {
+		return JVMInjectEnv
+	}
+
+	return strings.ToLower(j.Inject)
+}
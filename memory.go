@@ -0,0 +1,77 @@
+package worker
+
+import (
+	"fmt"
+	"strings"
+
+	"code.cloudfoundry.org/bytefmt"
+)
+
+const (
+	// SwapUnlimited allows the container to use all the host swap.
+	SwapUnlimited = "unlimited"
+	// SwapDisabled prevents the container from using swap.
+	SwapDisabled = "disabled"
+)
+
+// minMemory is the minimum memory limit accepted by docker
+const minMemory = 6 * bytefmt.MEGABYTE
+
+// MemoryBytes holds the parsed memory limits of a container, in bytes.
+type MemoryBytes struct {
+	Limit uint64
+	// Swap is the swap on top of Limit, ignored if SwapUnlimited is set
+	Swap          uint64
+	SwapUnlimited bool
+	Reservation   uint64
+}
+
+// DockerSwap returns the value for docker's MemorySwap, which is
+// the total of memory plus swap, or -1 for unlimited swap.
+func (m MemoryBytes) DockerSwap() int64 {
+	if m.SwapUnlimited {
+		return -1
+	}
+
+	return int64(m.Limit + m.Swap)
+}
+
+// Bytes parses and validates the memory limits.
+func (m ContainerMemory) Bytes() (MemoryBytes, error) {
+	var bytes MemoryBytes
+
+	if strings.TrimSpace(m.Limit) == "" {
+		return bytes, fmt.Errorf("invalid memory limit: must be defined")
+	}
+
+	limit, err := bytefmt.ToBytes(m.Limit)
+	if err != nil {
+		return bytes, fmt.Errorf("invalid memory limit '%s': %w", m.Limit, err)
+	}
+	if limit < minMemory {
+		return bytes, fmt.Errorf("invalid memory limit '%s': must be at least %s", m.Limit, bytefmt.ByteSize(minMemory))
+	}
+	bytes.Limit = limit
+
+	switch strings.ToLower(strings.TrimSpace(m.Swap)) {
+	case "", SwapDisabled, "0":
+	case SwapUnlimited:
+		bytes.SwapUnlimited = true
+	default:
+		if bytes.Swap, err = bytefmt.ToBytes(m.Swap); err != nil {
+			return bytes, fmt.Errorf("invalid memory swap '%s': %w", m.Swap, err)
+		}
+	}
+
+	if m.Reservation != "" {
+		if bytes.Reservation, err = bytefmt.ToBytes(m.Reservation); err != nil {
+			return bytes, fmt.Errorf("invalid memory reservation '%s': %w", m.Reservation, err)
+		}
+
+		if bytes.Reservation > bytes.Limit {
+			return bytes, fmt.Errorf("invalid memory reservation '%s': must not exceed the memory limit '%s'", m.Reservation, m.Limit)
+		}
+	}
+
+	return bytes, nil
+}
@@ -0,0 +1,283 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// PortBinding is a single host port bound to a container port.
+type PortBinding struct {
+	HostIP        string `json:"host_ip,omitempty"`
+	HostPort      int    `json:"host_port"`
+	ContainerPort int    `json:"container_port"`
+	Proto         string `json:"protocol"`
+}
+
+func (b PortBinding) String() string {
+	if b.HostIP != "" {
+		return fmt.Sprintf("%s/%s", net.JoinHostPort(b.HostIP, strconv.Itoa(b.HostPort)), b.Proto)
+	}
+
+	return fmt.Sprintf("%d/%s", b.HostPort, b.Proto)
+}
+
+// Conflicts returns whether both bindings use the same host port.
+func (b PortBinding) Conflicts(other PortBinding) bool {
+	if b.HostPort != other.HostPort || b.Proto != other.Proto {
+		return false
+	}
+
+	return isAnyIP(b.HostIP) || isAnyIP(other.HostIP) || net.ParseIP(b.HostIP).Equal(net.ParseIP(other.HostIP))
+}
+
+func isAnyIP(ip string) bool {
+	return ip == "" || net.ParseIP(ip).IsUnspecified()
+}
+
+var validProtocols = map[string]bool{
+	"tcp":  true,
+	"udp":  true,
+	"sctp": true,
+}
+
+// Expand returns every port binding defined by the bind, e.g. "25565-25570"
+// with protocol "tcp+udp" expands into 12 bindings.
+func (b ContainerNetworkBind) Expand() ([]PortBinding, error) {
+	hostIP, hostPort := splitIPPort(b.Addr)
+	hostIP, err := parseIP(hostIP)
+	if err != nil {
+		return nil, err
+	}
+
+	if hostPort == "" {
+		return nil, errors.New("undefined port for bind")
+	}
+
+	hostStart, hostEnd, err := parsePortRange(hostPort)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port '%s': %w", b.Addr, err)
+	}
+
+	containerStart, containerEnd := hostStart, hostEnd
+	if b.Private != "" {
+		containerStart, containerEnd, err = parsePortRange(b.Private)
+		if err != nil {
+			return nil, fmt.Errorf("invalid private port '%s': %w", b.Private, err)
+		}
+	}
+
+	if hostEnd-hostStart != containerEnd-containerStart {
+		return nil, fmt.Errorf("port range '%s' and private range '%s' must have the same size", hostPort, b.Private)
+	}
+
+	protocols, err := parseProtocols(b.Proto)
+	if err != nil {
+		return nil, err
+	}
+
+	bindings := make([]PortBinding, 0, (hostEnd-hostStart+1)*len(protocols))
+	for _, proto := range protocols {
+		for i := 0; i <= hostEnd-hostStart; i++ {
+			bindings = append(bindings, PortBinding{
+				HostIP:        hostIP,
+				HostPort:      hostStart + i,
+				ContainerPort: containerStart + i,
+				Proto:         proto,
+			})
+		}
+	}
+
+	return bindings, nil
+}
+
+// PortBindings expands all the network binds, failing if any
+// of them bind the same host port.
+func (n *ContainerNetwork) PortBindings() ([]PortBinding, error) {
+	if n == nil {
+		return nil, nil
+	}
+
+	binds := n.Binds[:len(n.Binds):len(n.Binds)]
+	for _, allocated := range n.Allocated {
+		binds = append(binds, allocated.Bind())
+	}
+
+	var bindings []PortBinding
+	for _, bind := range binds {
+		expanded, err := bind.Expand()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, b := range expanded {
+			for _, existing := range bindings {
+				if b.Conflicts(existing) {
+					return nil, fmt.Errorf("port %s bound more than once", b)
+				}
+			}
+		}
+
+		bindings = append(bindings, expanded...)
+	}
+
+	return bindings, nil
+}
+
+// DefaultGamePort is the container port of the game server,
+// when the network doesn't bind any static port.
+const DefaultGamePort = 25565
+
+// GamePort returns the container port of the game server, the container
+// port of the first static bind, or DefaultGamePort if there is none.
+func (n *ContainerNetwork) GamePort() int {
+	if n == nil || len(n.Binds) == 0 {
+		return DefaultGamePort
+	}
+
+	bindings, err := n.Binds[0].Expand()
+	if err != nil || len(bindings) == 0 {
+		return DefaultGamePort
+	}
+
+	return bindings[0].ContainerPort
+}
+
+// Validate checks whether the port allocation request is valid.
+func (a ContainerPortAllocation) Validate() error {
+	if a.Count < 1 {
+		return fmt.Errorf("invalid port allocation count %d: must be at least 1", a.Count)
+	}
+
+	if len(a.Names) > a.Count || len(a.Private) > a.Count {
+		return fmt.Errorf("invalid port allocation: more names or private ports than the %d allocated", a.Count)
+	}
+
+	names := make(map[string]bool)
+	for i := 0; i < a.Count; i++ {
+		name := a.Name(i)
+		if names[name] {
+			return fmt.Errorf("invalid port allocation: name '%s' used more than once", name)
+		}
+		names[name] = true
+	}
+
+	for _, private := range a.Private {
+		if _, err := parsePort(private); err != nil {
+			return fmt.Errorf("invalid port allocation private port: %w", err)
+		}
+	}
+
+	_, err := parseProtocols(a.Proto)
+	return err
+}
+
+// Name returns the name of the port with the given index.
+func (a ContainerPortAllocation) Name(i int) string {
+	if i < len(a.Names) && a.Names[i] != "" {
+		return a.Names[i]
+	}
+
+	if i == 0 {
+		return "game"
+	}
+
+	return strconv.Itoa(i)
+}
+
+// Protocols returns the protocols of the allocated ports.
+func (a ContainerPortAllocation) Protocols() []string {
+	protocols, err := parseProtocols(a.Proto)
+	if err != nil {
+		return nil
+	}
+
+	return protocols
+}
+
+// ParsePortRange parses a port, or a range of ports like "25565-25570".
+func ParsePortRange(rawPort string) (start int, end int, err error) {
+	return parsePortRange(rawPort)
+}
+
+func parsePortRange(rawPort string) (int, int, error) {
+	parts := strings.SplitN(rawPort, "-", 2)
+
+	start, err := parsePort(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	end := start
+	if len(parts) == 2 {
+		if end, err = parsePort(parts[1]); err != nil {
+			return 0, 0, err
+		}
+
+		if end < start {
+			return 0, 0, fmt.Errorf("range %s is reversed", rawPort)
+		}
+	}
+
+	return start, end, nil
+}
+
+func parsePort(rawPort string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(rawPort))
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("'%s' is not a port between 1 and 65535", rawPort)
+	}
+
+	return port, nil
+}
+
+func parseProtocols(rawProto string) ([]string, error) {
+	if rawProto == "" {
+		return []string{"tcp"}, nil
+	}
+
+	protocols := strings.Split(strings.ToLower(rawProto), "+")
+	for i, proto := range protocols {
+		if !validProtocols[proto] {
+			return nil, fmt.Errorf("invalid protocol '%s'", proto)
+		}
+
+		for _, p := range protocols[:i] {
+			if p == proto {
+				return nil, fmt.Errorf("protocol '%s' defined more than once", proto)
+			}
+		}
+	}
+
+	return protocols, nil
+}
+
+func parseIP(rawIP string) (string, error) {
+	// Strip [] from IPV6 addresses
+	ip, _, err := net.SplitHostPort(rawIP + ":")
+	if err != nil {
+		return "", fmt.Errorf("invalid ip address %v: %s", rawIP, err)
+	}
+	if ip != "" && net.ParseIP(ip) == nil {
+		return "", fmt.Errorf("invalid ip address: %s", ip)
+	}
+
+	return ip, nil
+}
+
+func splitIPPort(rawIP string) (string, string) {
+	parts := strings.Split(rawIP, ":")
+	length := len(parts)
+
+	switch length {
+	case 1:
+		return "", parts[0]
+	case 2:
+		return parts[0], parts[1]
+	default:
+		// IPV6
+		return strings.Join(parts[:length-1], ":"), parts[length-1]
+	}
+}
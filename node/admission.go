@@ -0,0 +1,346 @@
+package node
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"code.cloudfoundry.org/bytefmt"
+	"github.com/PanelMc/worker"
+)
+
+// AdmissionPolicy defines the checks made before creating a new server,
+// to avoid placing more servers than the node can handle.
+// Zero values disable the respective check.
+type AdmissionPolicy struct {
+	// MemoryOvercommit is the ratio of the available host memory that can be
+	// allocated to servers, e.g. 1.5 allows allocating 150% of the memory.
+	MemoryOvercommit float64
+	// SwapOvercommit is the ratio of the host swap that can be allocated to servers.
+	SwapOvercommit float64
+	// ReservedMemory is the memory, in bytes, reserved for the host and
+	// not available to servers.
+	ReservedMemory uint64
+	// MaxServers is the maximum number of servers in this node.
+	MaxServers int
+	// MinFreeDisk is the minimum free space, in bytes, required on
+	// the data filesystems of the server.
+	MinFreeDisk uint64
+}
+
+// RejectionReason identifies why a server was rejected.
+type RejectionReason string
+
+const (
+	// RejectionMemory indicates there is not enough memory for the server.
+	RejectionMemory RejectionReason = "memory"
+	// RejectionSwap indicates there is not enough swap for the server.
+	RejectionSwap RejectionReason = "swap"
+	// RejectionServers indicates the node reached the maximum number of servers.
+	RejectionServers RejectionReason = "servers"
+	// RejectionDisk indicates there is not enough free disk space for the server.
+	RejectionDisk RejectionReason = "disk"
+	// RejectionPorts indicates there are not enough free ports in the port pool.
+	RejectionPorts RejectionReason = "ports"
+)
+
+// Rejection describes a failed admission check.
+type Rejection struct {
+	Reason    RejectionReason `json:"reason"`
+	Message   string          `json:"message"`
+	Requested uint64          `json:"requested"`
+	Available uint64          `json:"available"`
+}
+
+// AdmissionError is returned when a server is rejected by the admission policy.
+type AdmissionError struct {
+	Rejections []Rejection `json:"rejections"`
+}
+
+func (e *AdmissionError) Error() string {
+	messages := make([]string, len(e.Rejections))
+	for i, r := range e.Rejections {
+		messages[i] = r.Message
+	}
+
+	return "server rejected: " + strings.Join(messages, "; ")
+}
+
+// Is matches worker.ErrResourceRejected.
+func (e *AdmissionError) Is(target error) bool {
+	return target == worker.ErrResourceRejected
+}
+
+var admission struct {
+	sync.Mutex
+	policy AdmissionPolicy
+	pool   PortPool
+	// pending holds the resources of the servers admitted but not yet created
+	pending map[string]worker.ContainerOptions
+}
+
+// SetAdmissionPolicy sets the policy used to admit new servers.
+func SetAdmissionPolicy(policy AdmissionPolicy) {
+	admission.Lock()
+	defer admission.Unlock()
+
+	admission.policy = policy
+}
+
+// Admit checks whether the node can host a server with the given options,
+// and whether its ports are free. The ports requested from the pool are
+// allocated and stored in the options.
+// On success, the resources are reserved until release is called, which
+// should happen once the server is registered or its creation failed.
+// Admitting a server already being created, or registered, fails.
+func Admit(opts *worker.ContainerOptions) (release func(), err error) {
+	admission.Lock()
+	defer admission.Unlock()
+
+	if _, pending := admission.pending[opts.ContainerName]; pending {
+		return nil, fmt.Errorf("server '%s' is already being created", opts.ContainerName)
+	}
+	if _, exists := worker.GetServer(opts.ContainerName); exists {
+		return nil, fmt.Errorf("server '%s' already exists", opts.ContainerName)
+	}
+
+	if err := allocatePorts(opts); err != nil {
+		return nil, err
+	}
+
+	if err := checkPorts(*opts); err != nil {
+		return nil, err
+	}
+
+	allocated := allocatedWithPending()
+	if err := admission.policy.check(allocated, *opts); err != nil {
+		return nil, err
+	}
+
+	if admission.pending == nil {
+		admission.pending = make(map[string]worker.ContainerOptions)
+	}
+	admission.pending[opts.ContainerName] = *opts
+
+	name := opts.ContainerName
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			admission.Lock()
+			defer admission.Unlock()
+
+			delete(admission.pending, name)
+		})
+	}, nil
+}
+
+// PendingNames returns the names of the servers admitted but not yet created.
+func PendingNames() []string {
+	admission.Lock()
+	defer admission.Unlock()
+
+	names := make([]string, 0, len(admission.pending))
+	for name := range admission.pending {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// PendingImages returns the images of the servers admitted but not yet created.
+func PendingImages() []string {
+	admission.Lock()
+	defer admission.Unlock()
+
+	images := make([]string, 0, len(admission.pending))
+	for _, opts := range admission.pending {
+		images = append(images, opts.Image.ID)
+		if opts.Image.Pinned != "" {
+			images = append(images, opts.Image.Pinned)
+		}
+	}
+
+	return images
+}
+
+// AdmitResize checks whether the node can host the server with its resources
+// changed from current to updated.
+func AdmitResize(current, updated worker.ContainerOptions) error {
+	admission.Lock()
+	defer admission.Unlock()
+
+	return admitResize(current, updated)
+}
+
+// AdmitRecreate checks whether the node can host the server recreated with
+// the updated options, allocating the ports it requests.
+func AdmitRecreate(current worker.ContainerOptions, updated *worker.ContainerOptions) error {
+	admission.Lock()
+	defer admission.Unlock()
+
+	if err := allocatePorts(updated); err != nil {
+		return err
+	}
+
+	if err := checkPorts(*updated); err != nil {
+		return err
+	}
+
+	return admitResize(current, *updated)
+}
+
+func admitResize(current, updated worker.ContainerOptions) error {
+	allocated := allocatedWithPending()
+	if _, managed := worker.GetServer(current.ContainerName); managed {
+		memory, swap, err := parseMemory(current.Memory)
+		if err == nil && memory <= allocated.Memory && swap <= allocated.Swap {
+			allocated.Memory -= memory
+			allocated.Swap -= swap
+		}
+	}
+
+	// Resizing doesn't use more disk space
+	policy := admission.policy
+	policy.MinFreeDisk = 0
+
+	return policy.checkResources(allocated, updated)
+}
+
+func allocatedWithPending() Allocation {
+	allocated := Allocated()
+	for _, pending := range admission.pending {
+		memory, swap, err := parseMemory(pending.Memory)
+		if err != nil {
+			continue
+		}
+
+		allocated.Servers++
+		allocated.Memory += memory
+		allocated.Swap += swap
+	}
+
+	return allocated
+}
+
+func (p AdmissionPolicy) check(allocated Allocation, opts worker.ContainerOptions) error {
+	var rejections []Rejection
+
+	if p.MaxServers > 0 && allocated.Servers >= p.MaxServers {
+		rejections = append(rejections, Rejection{
+			Reason:    RejectionServers,
+			Message:   fmt.Sprintf("node reached the maximum of %d servers", p.MaxServers),
+			Requested: 1,
+			Available: 0,
+		})
+	}
+
+	err := p.checkResources(allocated, opts)
+	if admissionErr, ok := err.(*AdmissionError); ok {
+		rejections = append(rejections, admissionErr.Rejections...)
+	} else if err != nil {
+		return err
+	}
+
+	if len(rejections) > 0 {
+		return &AdmissionError{rejections}
+	}
+
+	return nil
+}
+
+func (p AdmissionPolicy) checkResources(allocated Allocation, opts worker.ContainerOptions) error {
+	requested, err := opts.Memory.Bytes()
+	if err != nil {
+		return err
+	}
+	memory, swap := requested.Limit, requested.Swap
+
+	var rejections []Rejection
+
+	if p.MemoryOvercommit > 0 || p.SwapOvercommit > 0 {
+		hostMemory, hostSwap, err := memoryInfo()
+		if err != nil {
+			return fmt.Errorf("failed to read memory info: %w", err)
+		}
+
+		if p.MemoryOvercommit > 0 {
+			var total uint64
+			if hostMemory.Total > p.ReservedMemory {
+				total = uint64(float64(hostMemory.Total-p.ReservedMemory) * p.MemoryOvercommit)
+			}
+
+			if r := checkCapacity(RejectionMemory, "memory", memory, allocated.Memory, total); r != nil {
+				rejections = append(rejections, *r)
+			}
+		}
+
+		if p.SwapOvercommit > 0 && requested.SwapUnlimited {
+			rejections = append(rejections, Rejection{
+				Reason:    RejectionSwap,
+				Message:   "unlimited swap is not allowed when limiting the swap overcommit",
+				Available: hostSwap.Total,
+			})
+		} else if p.SwapOvercommit > 0 {
+			total := uint64(float64(hostSwap.Total) * p.SwapOvercommit)
+
+			if r := checkCapacity(RejectionSwap, "swap", swap, allocated.Swap, total); r != nil {
+				rejections = append(rejections, *r)
+			}
+		}
+	}
+
+	if p.MinFreeDisk > 0 {
+		for _, dir := range DataDirs(opts.Binds) {
+			disk, err := diskInfo(existingParent(dir))
+			if err != nil {
+				return fmt.Errorf("failed to read disk info for '%s': %w", dir, err)
+			}
+
+			if disk.Available < p.MinFreeDisk {
+				rejections = append(rejections, Rejection{
+					Reason: RejectionDisk,
+					Message: fmt.Sprintf("only %s free on '%s', %s required",
+						bytefmt.ByteSize(disk.Available), dir, bytefmt.ByteSize(p.MinFreeDisk)),
+					Requested: p.MinFreeDisk,
+					Available: disk.Available,
+				})
+			}
+		}
+	}
+
+	if len(rejections) > 0 {
+		return &AdmissionError{rejections}
+	}
+
+	return nil
+}
+
+func checkCapacity(reason RejectionReason, name string, requested, allocated, total uint64) *Rejection {
+	var available uint64
+	if total > allocated {
+		available = total - allocated
+	}
+
+	if requested <= available {
+		return nil
+	}
+
+	return &Rejection{
+		Reason: reason,
+		Message: fmt.Sprintf("requested %s of %s, only %s available",
+			bytefmt.ByteSize(requested), name, bytefmt.ByteSize(available)),
+		Requested: requested,
+		Available: available,
+	}
+}
+
+// parseMemory returns the memory and swap limits, in bytes.
+// Unlimited swap is accounted as 0, as it's not reserved.
+func parseMemory(memory worker.ContainerMemory) (limit uint64, swap uint64, err error) {
+	bytes, err := memory.Bytes()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return bytes.Limit, bytes.Swap, nil
+}
@@ -0,0 +1,416 @@
+package node
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/bytefmt"
+	"github.com/PanelMc/worker"
+)
+
+// Disk usage levels of a server, compared to its quota
+const (
+	diskLevelOK = iota
+	diskLevelWarning
+	diskLevelOverQuota
+)
+
+// DiskQuotaError is returned when a server uses more disk than its quota.
+type DiskQuotaError struct {
+	Server string `json:"server"`
+	Usage  uint64 `json:"usage"`
+	Quota  uint64 `json:"quota"`
+}
+
+func (e *DiskQuotaError) Error() string {
+	return fmt.Sprintf("server '%s' is over its disk quota: %s used of %s",
+		e.Server, bytefmt.ByteSize(e.Usage), bytefmt.ByteSize(e.Quota))
+}
+
+// Is matches worker.ErrResourceRejected.
+func (e *DiskQuotaError) Is(target error) bool {
+	return target == worker.ErrResourceRejected
+}
+
+// DiskUsage is the disk space used by the bind host directories of a server.
+type DiskUsage struct {
+	Server string `json:"server"`
+	// Usage in bytes
+	Usage uint64 `json:"usage"`
+	// Quota in bytes, 0 if unlimited
+	Quota     uint64    `json:"quota"`
+	ScannedAt time.Time `json:"scanned_at"`
+}
+
+var disk = struct {
+	sync.Mutex
+	servers map[string]*serverDisk
+}{
+	servers: make(map[string]*serverDisk),
+}
+
+type serverDisk struct {
+	// Serializes the scans of the server
+	sync.Mutex
+	scanner diskScanner
+	usage   DiskUsage
+	level   int
+}
+
+// GetDiskUsage returns the disk usage of the given server as of the
+// last scan, and false if it was not scanned yet.
+func GetDiskUsage(server string) (DiskUsage, bool) {
+	disk.Lock()
+	defer disk.Unlock()
+
+	d, ok := disk.servers[server]
+	if !ok || d.usage.ScannedAt.IsZero() {
+		return DiskUsage{}, false
+	}
+
+	return d.usage, true
+}
+
+// DiskUsages returns the disk usage of all the scanned servers.
+func DiskUsages() []DiskUsage {
+	disk.Lock()
+	defer disk.Unlock()
+
+	usages := make([]DiskUsage, 0, len(disk.servers))
+	for _, d := range disk.servers {
+		if !d.usage.ScannedAt.IsZero() {
+			usages = append(usages, d.usage)
+		}
+	}
+
+	return usages
+}
+
+// ScanDisk scans the bind host directories of the given options, updating
+// the stored disk usage of the server.
+func ScanDisk(opts worker.ContainerOptions) (DiskUsage, error) {
+	d := serverDiskOf(opts.ContainerName)
+
+	d.Lock()
+	defer d.Unlock()
+
+	usage := DiskUsage{Server: opts.ContainerName}
+	if opts.Disk != nil {
+		quota, err := opts.Disk.QuotaBytes()
+		if err != nil {
+			return DiskUsage{}, err
+		}
+		usage.Quota = quota
+	}
+
+	size, err := d.scanner.scan(QuotaDirs(opts))
+	if err != nil {
+		return DiskUsage{}, fmt.Errorf("failed to scan the disk usage of server '%s': %w", opts.ContainerName, err)
+	}
+	usage.Usage = size
+	usage.ScannedAt = time.Now()
+
+	disk.Lock()
+	d.usage = usage
+	disk.Unlock()
+
+	return usage, nil
+}
+
+// CheckDiskQuota scans the disk usage of the given options, returning
+// a *DiskQuotaError if the server is over its quota.
+func CheckDiskQuota(opts worker.ContainerOptions) error {
+	if opts.Disk == nil {
+		return nil
+	}
+
+	usage, err := ScanDisk(opts)
+	if err != nil {
+		return err
+	}
+
+	if usage.Usage > usage.Quota {
+		return &DiskQuotaError{Server: usage.Server, Usage: usage.Usage, Quota: usage.Quota}
+	}
+
+	return nil
+}
+
+// StartDiskMonitor periodically scans the disk usage of all the servers,
+// warning the servers approaching or over their quota.
+// The returned function stops the monitor, waiting for the running scan.
+func StartDiskMonitor(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			scanServers()
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-stopped
+	}
+}
+
+func scanServers() {
+	servers := worker.Servers()
+
+	// Forget the servers no longer managed by the worker
+	ids := make(map[string]bool, len(servers))
+	for _, s := range servers {
+		ids[s.ID()] = true
+	}
+	disk.Lock()
+	for id := range disk.servers {
+		if !ids[id] {
+			delete(disk.servers, id)
+		}
+	}
+	disk.Unlock()
+
+	for _, s := range servers {
+		opts := s.Container().Options()
+		usage, err := ScanDisk(opts)
+		if err != nil {
+			logger.Warnf("%s", err)
+			continue
+		}
+
+		if opts.Disk != nil {
+			enforceQuota(s, *opts.Disk, usage)
+		}
+	}
+}
+
+// enforceQuota warns the server when its usage reaches a higher level.
+func enforceQuota(s worker.Server, quota worker.ContainerDisk, usage DiskUsage) {
+	warning, warn, err := quota.WarningBytes()
+	if err != nil {
+		return
+	}
+
+	level := diskLevelOK
+	if usage.Usage > usage.Quota {
+		level = diskLevelOverQuota
+	} else if warn && usage.Usage >= warning {
+		level = diskLevelWarning
+	}
+
+	d := serverDiskOf(s.ID())
+	disk.Lock()
+	previous := d.level
+	d.level = level
+	disk.Unlock()
+
+	if level <= previous {
+		return
+	}
+
+	used, total := bytefmt.ByteSize(usage.Usage), bytefmt.ByteSize(usage.Quota)
+	switch level {
+	case diskLevelWarning:
+		logger.Warnf("Server '%s' is approaching its disk quota: %s used of %s", s.ID(), used, total)
+		s.Container().Logger().Warnf("Disk usage is approaching the quota: %s used of %s", used, total)
+	case diskLevelOverQuota:
+		logger.Warnf("Server '%s' is over its disk quota: %s used of %s", s.ID(), used, total)
+		s.Container().Logger().Warnf("Disk usage is over the quota: %s used of %s", used, total)
+	}
+}
+
+func serverDiskOf(server string) *serverDisk {
+	disk.Lock()
+	defer disk.Unlock()
+
+	d, ok := disk.servers[server]
+	if !ok {
+		d = &serverDisk{}
+		disk.servers[server] = d
+	}
+
+	return d
+}
+
+// HostDirs returns the templated host directories of the bind type binds.
+func HostDirs(opts worker.ContainerOptions) []string {
+	dirs := make([]string, 0, len(opts.Binds))
+	for _, bind := range opts.Binds {
+		if bind.BindType() == worker.BindTypeBind {
+			dirs = append(dirs, opts.Template(bind.HostDir))
+		}
+	}
+
+	return dirs
+}
+
+// QuotaDirs returns the host directories accounted by the disk quota of the
+// server: the writable binds that aren't shared with other managed servers.
+func QuotaDirs(opts worker.ContainerOptions) []string {
+	shared := make(map[string]bool)
+	for _, s := range worker.Servers() {
+		if s.ID() == opts.ContainerName {
+			continue
+		}
+
+		for _, dir := range HostDirs(s.Container().Options()) {
+			shared[filepath.Clean(dir)] = true
+		}
+	}
+
+	dirs := make([]string, 0, len(opts.Binds))
+	for _, bind := range opts.Binds {
+		if bind.BindType() != worker.BindTypeBind || bind.Mode == "ro" {
+			continue
+		}
+
+		dir := filepath.Clean(opts.Template(bind.HostDir))
+		if !shared[dir] {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	return dirs
+}
+
+// diskScanner computes the size of directory trees. The entries of each
+// directory are cached by its modification time, so unchanged directories
+// aren't listed again. Their files are still stat'ed on every scan, as the
+// files modified in place, e.g. region files and logs, don't change it.
+type diskScanner struct {
+	dirs map[string]*scannedDir
+}
+
+type scannedDir struct {
+	modTime time.Time
+	files   []string
+	dirs    []string
+}
+
+func (s *diskScanner) scan(roots []string) (uint64, error) {
+	if s.dirs == nil {
+		s.dirs = make(map[string]*scannedDir)
+	}
+
+	seen := make(map[string]bool)
+	var total uint64
+	for _, root := range roots {
+		size, err := s.scanDir(filepath.Clean(root), seen)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return 0, err
+		}
+		total += size
+	}
+
+	// Drop the removed directories from the cache
+	for dir := range s.dirs {
+		if !seen[dir] {
+			delete(s.dirs, dir)
+		}
+	}
+
+	return total, nil
+}
+
+func (s *diskScanner) scanDir(dir string, seen map[string]bool) (uint64, error) {
+	// Overlapping binds are only counted once
+	if seen[dir] {
+		return 0, nil
+	}
+	seen[dir] = true
+
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return 0, err
+	}
+	if !info.IsDir() {
+		return fileSize(info), nil
+	}
+
+	cached, ok := s.dirs[dir]
+	if !ok || !cached.modTime.Equal(info.ModTime()) {
+		cached, err = listDir(dir, info.ModTime())
+		if err != nil {
+			return 0, err
+		}
+		s.dirs[dir] = cached
+	}
+
+	var total uint64
+	for _, name := range cached.files {
+		info, err := os.Lstat(filepath.Join(dir, name))
+		if err != nil {
+			// Removed since listed
+			continue
+		}
+		total += fileSize(info)
+	}
+
+	for _, name := range cached.dirs {
+		size, err := s.scanDir(filepath.Join(dir, name), seen)
+		if err != nil {
+			if os.IsNotExist(err) || os.IsPermission(err) {
+				continue
+			}
+			return 0, err
+		}
+		total += size
+	}
+
+	return total, nil
+}
+
+func listDir(dir string, modTime time.Time) (*scannedDir, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return nil, err
+	}
+
+	scanned := &scannedDir{modTime: modTime}
+	for _, name := range names {
+		info, err := os.Lstat(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+
+		if info.IsDir() {
+			scanned.dirs = append(scanned.dirs, name)
+		} else {
+			scanned.files = append(scanned.files, name)
+		}
+	}
+
+	return scanned, nil
+}
+
+func fileSize(info os.FileInfo) uint64 {
+	if !info.Mode().IsRegular() {
+		return 0
+	}
+
+	return uint64(info.Size())
+}
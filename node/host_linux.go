@@ -0,0 +1,119 @@
+//go:build linux
+// +build linux
+
+package node
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func cpuInfo() (CPUInfo, error) {
+	idle1, total1, err := readCPUTimes()
+	if err != nil {
+		return CPUInfo{}, err
+	}
+
+	time.Sleep(time.Millisecond * 200)
+
+	idle2, total2, err := readCPUTimes()
+	if err != nil {
+		return CPUInfo{}, err
+	}
+
+	cores := runtime.NumCPU()
+	info := CPUInfo{
+		Cores:     cores,
+		Available: float64(cores),
+	}
+	if total2 > total1 {
+		info.Available = float64(idle2-idle1) / float64(total2-total1) * float64(cores)
+	}
+
+	return info, nil
+}
+
+// readCPUTimes reads the aggregated idle and total CPU times from /proc/stat
+func readCPUTimes() (idle, total uint64, err error) {
+	content, err := ioutil.ReadFile("/proc/stat")
+	if err != nil {
+		return
+	}
+
+	line := strings.SplitN(string(content), "\n", 2)[0]
+	fields := strings.Fields(line)
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return 0, 0, fmt.Errorf("unexpected /proc/stat format")
+	}
+
+	for i, field := range fields[1:] {
+		value, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		total += value
+		// idle and iowait columns
+		if i == 3 || i == 4 {
+			idle += value
+		}
+	}
+
+	return
+}
+
+func memoryInfo() (memory MemoryInfo, swap MemoryInfo, err error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		// Values are in kB
+		value *= 1024
+
+		switch strings.TrimSuffix(fields[0], ":") {
+		case "MemTotal":
+			memory.Total = value
+		case "MemAvailable":
+			memory.Available = value
+		case "SwapTotal":
+			swap.Total = value
+		case "SwapFree":
+			swap.Available = value
+		}
+	}
+
+	err = scanner.Err()
+	return
+}
+
+func diskInfo(path string) (DiskInfo, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return DiskInfo{}, err
+	}
+
+	return DiskInfo{
+		Total:     stat.Blocks * uint64(stat.Bsize),
+		Available: stat.Bavail * uint64(stat.Bsize),
+	}, nil
+}
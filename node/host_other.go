@@ -0,0 +1,23 @@
+//go:build !linux
+// +build !linux
+
+package node
+
+import (
+	"errors"
+	"runtime"
+)
+
+var errUnsupported = errors.New("host resources are only supported on linux")
+
+func cpuInfo() (CPUInfo, error) {
+	return CPUInfo{Cores: runtime.NumCPU()}, nil
+}
+
+func memoryInfo() (MemoryInfo, MemoryInfo, error) {
+	return MemoryInfo{}, MemoryInfo{}, errUnsupported
+}
+
+func diskInfo(path string) (DiskInfo, error) {
+	return DiskInfo{}, errUnsupported
+}
@@ -0,0 +1,195 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/PanelMc/worker"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+// Info holds the resources of the node the worker is running on,
+// along with the resources allocated to the managed servers.
+type Info struct {
+	CPU       CPUInfo    `json:"cpu"`
+	Memory    MemoryInfo `json:"memory"`
+	Swap      MemoryInfo `json:"swap"`
+	Disks     []DiskInfo `json:"disks"`
+	Docker    DockerInfo `json:"docker"`
+	Allocated Allocation `json:"allocated"`
+}
+
+// CPUInfo holds the CPU resources of the node.
+type CPUInfo struct {
+	// Number of logical cores
+	Cores int `json:"cores"`
+	// Number of idle cores, e.g. 2.5 means two and a half cores are idle
+	Available float64 `json:"available"`
+}
+
+// MemoryInfo holds the memory or swap resources of the node, in bytes.
+type MemoryInfo struct {
+	Total     uint64 `json:"total"`
+	Available uint64 `json:"available"`
+}
+
+// DiskInfo holds the resources of the filesystem containing Path, in bytes.
+type DiskInfo struct {
+	Path      string `json:"path"`
+	Total     uint64 `json:"total"`
+	Available uint64 `json:"available"`
+}
+
+// DockerInfo holds information about the docker daemon.
+type DockerInfo struct {
+	Version       string `json:"version"`
+	APIVersion    string `json:"api_version"`
+	StorageDriver string `json:"storage_driver"`
+	Containers    int    `json:"containers"`
+	// UsernsRemap is whether the daemon remaps the container users
+	UsernsRemap bool `json:"userns_remap"`
+}
+
+// Allocation holds the resources allocated to the managed servers.
+type Allocation struct {
+	Servers int `json:"servers"`
+	// Sum of the memory limits, in bytes
+	Memory uint64 `json:"memory"`
+	// Sum of the swap limits, in bytes
+	Swap uint64 `json:"swap"`
+	// Sum of the CPU core limits, servers without limit are not accounted
+	CPU float64 `json:"cpu"`
+}
+
+// MemoryOvercommit returns the ratio between the allocated memory
+// and the total memory of the node. Values above 1 mean the node is overcommitted.
+func (i Info) MemoryOvercommit() float64 {
+	if i.Memory.Total == 0 {
+		return 0
+	}
+
+	return float64(i.Allocated.Memory) / float64(i.Memory.Total)
+}
+
+var logger = logrus.WithField("context", "node")
+
+// GetInfo collects the resources of the node. The disk resources are reported
+// for the filesystems containing the given data directories.
+func GetInfo(ctx context.Context, cli *client.Client, dataDirs []string) (Info, error) {
+	var info Info
+	var err error
+
+	if info.CPU, err = cpuInfo(); err != nil {
+		return info, fmt.Errorf("failed to read cpu info: %w", err)
+	}
+
+	if info.Memory, info.Swap, err = memoryInfo(); err != nil {
+		return info, fmt.Errorf("failed to read memory info: %w", err)
+	}
+
+	info.Disks = make([]DiskInfo, 0, len(dataDirs))
+	for _, dir := range dataDirs {
+		disk, err := diskInfo(existingParent(dir))
+		if err != nil {
+			return info, fmt.Errorf("failed to read disk info for '%s': %w", dir, err)
+		}
+
+		disk.Path = dir
+		info.Disks = append(info.Disks, disk)
+	}
+
+	if info.Docker, err = dockerInfo(ctx, cli); err != nil {
+		return info, fmt.Errorf("failed to read docker info: %w", err)
+	}
+
+	info.Allocated = Allocated()
+
+	return info, nil
+}
+
+// Allocated sums the resources allocated to all the servers managed by the worker.
+func Allocated() Allocation {
+	var allocation Allocation
+	for _, s := range worker.Servers() {
+		allocation.Servers++
+
+		options := s.Container().Options()
+		allocation.CPU += options.CPU.Cores
+
+		memory, swap, err := parseMemory(options.Memory)
+		if err != nil {
+			logger.Warnf("Failed to read the memory of server '%s': %s", s.ID(), err)
+			continue
+		}
+
+		allocation.Memory += memory
+		allocation.Swap += swap
+	}
+
+	return allocation
+}
+
+// DataDirs returns the base directories of the given binds, that is,
+// the host directory up to the first templated segment.
+func DataDirs(binds []worker.ContainerBind) []string {
+	dirs := make([]string, 0, len(binds))
+	seen := make(map[string]bool)
+	for _, bind := range binds {
+		if bind.BindType() != worker.BindTypeBind {
+			continue
+		}
+
+		dir := bind.HostDir
+		if i := strings.IndexAny(dir, "%{"); i >= 0 {
+			dir = filepath.Dir(dir[:i] + "x")
+		}
+		dir = filepath.Clean(dir)
+
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+
+	return dirs
+}
+
+func dockerInfo(ctx context.Context, cli *client.Client) (DockerInfo, error) {
+	version, err := cli.ServerVersion(ctx)
+	if err != nil {
+		return DockerInfo{}, err
+	}
+
+	info, err := cli.Info(ctx)
+	if err != nil {
+		return DockerInfo{}, err
+	}
+
+	return DockerInfo{
+		Version:       version.Version,
+		APIVersion:    version.APIVersion,
+		StorageDriver: info.Driver,
+		Containers:    info.Containers,
+		UsernsRemap:   usernsEnabled(info.SecurityOptions),
+	}, nil
+}
+
+// existingParent returns the closest existing directory to the given path,
+// as data directories may not be created yet.
+func existingParent(dir string) string {
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
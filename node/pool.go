@@ -0,0 +1,137 @@
+package node
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/PanelMc/worker"
+)
+
+// PortPool defines the host ports available for automatic allocation.
+type PortPool struct {
+	Ranges []PortRange
+}
+
+// PortRange is a range of ports, inclusive, on the given address.
+type PortRange struct {
+	HostIP string
+	Start  int
+	End    int
+}
+
+// SetPortPool sets the pool used to allocate ports to new servers.
+func SetPortPool(pool PortPool) {
+	admission.Lock()
+	defer admission.Unlock()
+
+	admission.pool = pool
+}
+
+// allocatePorts allocates the ports requested by the options from the pool,
+// storing them in the options network.
+func allocatePorts(opts *worker.ContainerOptions) error {
+	if opts.Network == nil || opts.Network.Allocate == nil || len(opts.Network.Allocated) > 0 {
+		return nil
+	}
+
+	request := *opts.Network.Allocate
+	if err := request.Validate(); err != nil {
+		return err
+	}
+	protocols := request.Protocols()
+
+	// Copy the network, as it may be shared with the preset
+	network := *opts.Network
+	network.Allocated = make([]worker.AllocatedPort, 0, request.Count)
+
+	used := usedPorts()
+	// The static binds of the server itself
+	static, err := (&worker.ContainerNetwork{Binds: network.Binds}).PortBindings()
+	if err != nil {
+		return err
+	}
+
+	isFree := func(binding worker.PortBinding) bool {
+		for _, u := range used {
+			if binding.Conflicts(u.PortBinding) {
+				return false
+			}
+		}
+
+		for _, s := range static {
+			if binding.Conflicts(s) {
+				return false
+			}
+		}
+
+		for _, allocated := range network.Allocated {
+			if binding.Conflicts(worker.PortBinding{HostIP: allocated.HostIP, HostPort: allocated.Port, Proto: binding.Proto}) {
+				return false
+			}
+		}
+
+		return isHostPortFree(binding)
+	}
+
+	for _, r := range admission.pool.Ranges {
+		for port := r.Start; port <= r.End && len(network.Allocated) < request.Count; port++ {
+			free := true
+			for _, proto := range protocols {
+				free = free && isFree(worker.PortBinding{HostIP: r.HostIP, HostPort: port, Proto: proto})
+			}
+			if !free {
+				continue
+			}
+
+			i := len(network.Allocated)
+			allocated := worker.AllocatedPort{
+				Name:   request.Name(i),
+				HostIP: r.HostIP,
+				Port:   port,
+				Proto:  request.Proto,
+			}
+			if i < len(request.Private) && request.Private[i] != "" {
+				allocated.Private = request.Private[i]
+			} else if i == 0 {
+				allocated.Private = strconv.Itoa(network.GamePort())
+			}
+
+			network.Allocated = append(network.Allocated, allocated)
+		}
+	}
+
+	if len(network.Allocated) < request.Count {
+		return &AdmissionError{[]Rejection{{
+			Reason:    RejectionPorts,
+			Message:   fmt.Sprintf("requested %d ports, only %d free in the port pool", request.Count, len(network.Allocated)),
+			Requested: uint64(request.Count),
+			Available: uint64(len(network.Allocated)),
+		}}}
+	}
+
+	opts.Network = &network
+	return nil
+}
+
+// isHostPortFree checks whether a process outside the worker is using the port.
+func isHostPortFree(binding worker.PortBinding) bool {
+	addr := net.JoinHostPort(binding.HostIP, strconv.Itoa(binding.HostPort))
+
+	switch binding.Proto {
+	case "tcp":
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return false
+		}
+		l.Close()
+	case "udp":
+		c, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			return false
+		}
+		c.Close()
+	}
+
+	return true
+}
@@ -0,0 +1,107 @@
+package node
+
+import (
+	"fmt"
+
+	"github.com/PanelMc/worker"
+)
+
+// PortConflictError is returned when a server binds a host
+// port already in use by another server.
+type PortConflictError struct {
+	Binding worker.PortBinding `json:"binding"`
+	// Server is the ID of the server holding the port
+	Server string `json:"server"`
+}
+
+func (e *PortConflictError) Error() string {
+	return fmt.Sprintf("port %s already in use by server '%s'", e.Binding, e.Server)
+}
+
+// Is matches worker.ErrResourceRejected.
+func (e *PortConflictError) Is(target error) bool {
+	return target == worker.ErrResourceRejected
+}
+
+// UsedPort is a host port in use by a server.
+type UsedPort struct {
+	worker.PortBinding
+	Server string `json:"server"`
+}
+
+// UsedPorts returns the host ports bound by all the servers managed
+// by the worker, including the ones being created, and the ones
+// with a stored spec not loaded yet, e.g. after a restart.
+func UsedPorts() []UsedPort {
+	admission.Lock()
+	defer admission.Unlock()
+
+	return usedPorts()
+}
+
+func usedPorts() []UsedPort {
+	used := make([]UsedPort, 0)
+	add := func(server string, network *worker.ContainerNetwork) {
+		bindings, err := network.PortBindings()
+		if err != nil {
+			logger.Warnf("Failed to read the ports of server '%s': %s", server, err)
+			return
+		}
+
+		for _, binding := range bindings {
+			used = append(used, UsedPort{binding, server})
+		}
+	}
+
+	known := make(map[string]bool)
+	for _, s := range worker.Servers() {
+		known[s.ID()] = true
+		add(s.ID(), s.Container().Options().Network)
+	}
+	for name, pending := range admission.pending {
+		known[name] = true
+		add(name, pending.Network)
+	}
+
+	names, err := worker.ListSpecs()
+	if err != nil {
+		logger.Warnf("Failed to list the stored specs: %s", err)
+	}
+	for _, name := range names {
+		if known[name] {
+			continue
+		}
+
+		opts, err := worker.LoadSpec(name)
+		if err != nil {
+			logger.Warnf("Failed to load the spec of server '%s': %s", name, err)
+			continue
+		}
+		add(name, opts.Network)
+	}
+
+	return used
+}
+
+// checkPorts checks whether the ports of the given options are
+// in use by another server.
+func checkPorts(opts worker.ContainerOptions) error {
+	bindings, err := opts.Network.PortBindings()
+	if err != nil {
+		return err
+	}
+
+	used := usedPorts()
+	for _, binding := range bindings {
+		for _, u := range used {
+			if u.Server != opts.ContainerName && binding.Conflicts(u.PortBinding) {
+				return &PortConflictError{
+					Binding: binding,
+					Server:  u.Server,
+				}
+			}
+		}
+	}
+
+	return nil
+}
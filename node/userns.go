@@ -0,0 +1,134 @@
+package node
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+)
+
+// DefaultUsernsRemapUser is the user used by docker for the
+// user namespace remapping when set to "default".
+const DefaultUsernsRemapUser = "dockremap"
+
+var userns = struct {
+	sync.Mutex
+	user string
+}{
+	user: DefaultUsernsRemapUser,
+}
+
+// IDMap is the host uid and gid that the root of the
+// containers is remapped to.
+type IDMap struct {
+	UID int `json:"uid"`
+	GID int `json:"gid"`
+}
+
+// Map returns the host ids of the given container ids.
+func (m *IDMap) Map(uid, gid int) (int, int) {
+	if m == nil {
+		return uid, gid
+	}
+
+	if uid >= 0 {
+		uid += m.UID
+	}
+	if gid >= 0 {
+		gid += m.GID
+	}
+
+	return uid, gid
+}
+
+// SetUsernsRemapUser sets the user configured as the "userns-remap"
+// of the docker daemon, used to read the subordinate ids.
+func SetUsernsRemapUser(user string) {
+	userns.Lock()
+	defer userns.Unlock()
+
+	if user == "" {
+		user = DefaultUsernsRemapUser
+	}
+	userns.user = user
+}
+
+// UsernsRemap returns the id map of the user namespace remapping
+// of the docker daemon, or nil if it's disabled.
+func UsernsRemap(ctx context.Context, cli *client.Client) (*IDMap, error) {
+	info, err := cli.Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !usernsEnabled(info.SecurityOptions) {
+		return nil, nil
+	}
+
+	userns.Lock()
+	user := userns.user
+	userns.Unlock()
+
+	uid, err := readSubID("/etc/subuid", user)
+	if err != nil {
+		return nil, err
+	}
+
+	gid, err := readSubID("/etc/subgid", user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IDMap{UID: uid, GID: gid}, nil
+}
+
+func usernsEnabled(securityOptions []string) bool {
+	opts, err := types.DecodeSecurityOptions(securityOptions)
+	if err != nil {
+		return false
+	}
+
+	for _, opt := range opts {
+		if opt.Name == "userns" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readSubID reads the first subordinate id of the user from the given file,
+// in the "<user>:<start>:<count>" format.
+func readSubID(file, user string) (int, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the remapped ids of '%s': %w", user, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(strings.TrimSpace(scanner.Text()), ":")
+		if len(fields) != 3 || fields[0] != user {
+			continue
+		}
+
+		id, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid subordinate id '%s' in %s: %w", fields[1], file, err)
+		}
+
+		return id, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	return 0, fmt.Errorf("no subordinate ids found for '%s' in %s", user, file)
+}
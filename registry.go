@@ -0,0 +1,114 @@
+package worker
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var registry struct {
+	sync.RWMutex
+	servers map[string]Server
+}
+
+// hooks are called when the servers start or stop being managed.
+var hooks struct {
+	sync.Mutex
+	registered []func(Server)
+	removed    []func(id string)
+}
+
+// OnServerRegistered adds a function called with every server registered from now on.
+func OnServerRegistered(f func(Server)) {
+	hooks.Lock()
+	defer hooks.Unlock()
+
+	hooks.registered = append(hooks.registered, f)
+}
+
+// OnServerRemoved adds a function called with the ID of every server removed from now on.
+func OnServerRemoved(f func(id string)) {
+	hooks.Lock()
+	defer hooks.Unlock()
+
+	hooks.removed = append(hooks.removed, f)
+}
+
+func registerServer(s Server) error {
+	registry.Lock()
+	if _, exists := registry.servers[s.ID()]; exists {
+		registry.Unlock()
+		return fmt.Errorf("server '%s' already exists", s.ID())
+	}
+
+	if registry.servers == nil {
+		registry.servers = make(map[string]Server)
+	}
+	registry.servers[s.ID()] = s
+	registry.Unlock()
+
+	hooks.Lock()
+	registered := hooks.registered
+	hooks.Unlock()
+	for _, f := range registered {
+		f(s)
+	}
+
+	return nil
+}
+
+// GetServer returns the managed server with the given ID, if present.
+func GetServer(id string) (Server, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	s, ok := registry.servers[id]
+	return s, ok
+}
+
+// LookupServer returns the managed server with the given ID,
+// or a *NotFoundError if there is none.
+func LookupServer(id string) (Server, error) {
+	s, ok := GetServer(id)
+	if !ok {
+		return nil, &NotFoundError{Server: id}
+	}
+
+	return s, nil
+}
+
+// RemoveServer stops managing the server with the given ID.
+func RemoveServer(id string) {
+	registry.Lock()
+	_, exists := registry.servers[id]
+	delete(registry.servers, id)
+	registry.Unlock()
+
+	if !exists {
+		return
+	}
+
+	hooks.Lock()
+	removed := hooks.removed
+	hooks.Unlock()
+	for _, f := range removed {
+		f(id)
+	}
+}
+
+// Servers returns all the servers managed by this worker, sorted by ID.
+func Servers() []Server {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	servers := make([]Server, 0, len(registry.servers))
+	for _, s := range registry.servers {
+		servers = append(servers, s)
+	}
+
+	sort.Slice(servers, func(i, j int) bool {
+		return servers[i].ID() < servers[j].ID()
+	})
+
+	return servers
+}
@@ -0,0 +1,133 @@
+package worker
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/docker/distribution/reference"
+)
+
+// Image pull policies
+const (
+	// PullAlways pulls the image on every server creation, checking for updates.
+	PullAlways = "always"
+	// PullIfNotPresent only pulls the image when it's not present on the node.
+	PullIfNotPresent = "if-not-present"
+	// PullNever never pulls the image, which must be preloaded on the node.
+	PullNever = "never"
+)
+
+// RegistryAuth defines the credentials of a docker registry.
+type RegistryAuth struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// IdentityToken is used instead of the username and password, if set
+	IdentityToken string `json:"identity_token,omitempty"`
+}
+
+var registries struct {
+	sync.RWMutex
+	auths map[string]RegistryAuth
+}
+
+// SetRegistryAuths sets the credentials used to pull images,
+// by registry host, e.g. "ghcr.io".
+func SetRegistryAuths(auths map[string]RegistryAuth) {
+	normalized := make(map[string]RegistryAuth, len(auths))
+	for host, auth := range auths {
+		normalized[NormalizeRegistryHost(host)] = auth
+	}
+
+	registries.Lock()
+	defer registries.Unlock()
+
+	registries.auths = normalized
+}
+
+// GetRegistryAuth returns the credentials of the registry of the given image.
+func GetRegistryAuth(image string) (RegistryAuth, bool, error) {
+	host, err := ImageRegistry(image)
+	if err != nil {
+		return RegistryAuth{}, false, err
+	}
+
+	registries.RLock()
+	defer registries.RUnlock()
+
+	auth, ok := registries.auths[host]
+	return auth, ok, nil
+}
+
+// ImageRegistry returns the registry host of the given image,
+// "docker.io" for the Docker Hub images.
+func ImageRegistry(image string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", fmt.Errorf("invalid image '%s': %w", image, err)
+	}
+
+	return NormalizeRegistryHost(reference.Domain(named)), nil
+}
+
+// NormalizeRegistryHost strips the scheme and path of the given registry
+// address, mapping the Docker Hub aliases to "docker.io".
+func NormalizeRegistryHost(host string) string {
+	host = strings.TrimPrefix(host, "https://")
+	host = strings.TrimPrefix(host, "http://")
+	if i := strings.Index(host, "/"); i >= 0 {
+		host = host[:i]
+	}
+
+	switch host {
+	case "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		return "docker.io"
+	}
+
+	return host
+}
+
+// Validate checks whether the image is valid.
+func (i ContainerImage) Validate() error {
+	if _, err := reference.ParseNormalizedNamed(i.ID); err != nil {
+		return fmt.Errorf("invalid image '%s': %w", i.ID, err)
+	}
+
+	if i.Build != nil {
+		if err := i.Build.Validate(); err != nil {
+			return err
+		}
+	}
+
+	switch i.PullPolicy {
+	case "", PullAlways, PullIfNotPresent, PullNever:
+	default:
+		return fmt.Errorf("invalid pull policy '%s': must be '%s', '%s' or '%s'", i.PullPolicy, PullAlways, PullIfNotPresent, PullNever)
+	}
+
+	return nil
+}
+
+// Policy returns the pull policy of the image, defaulting to "always".
+func (i ContainerImage) Policy() string {
+	if i.PullPolicy == "" {
+		return PullAlways
+	}
+
+	return i.PullPolicy
+}
+
+// Validate checks whether the build options are valid.
+func (b ContainerImageBuild) Validate() error {
+	if b.Context == "" {
+		return fmt.Errorf("invalid image build: missing context")
+	}
+
+	dockerfile := filepath.Clean(b.DockerfilePath())
+	if filepath.IsAbs(dockerfile) || dockerfile == ".." || strings.HasPrefix(dockerfile, "../") {
+		return fmt.Errorf("invalid dockerfile '%s': must be inside the build context", b.Dockerfile)
+	}
+
+	return nil
+}
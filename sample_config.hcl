@@ -16,9 +16,83 @@ server {
     }
 }
 
+// Bind host directories must be inside one of these paths
+bind_base_paths = ["/servers/"]
+
 presets_folder = "./presets/"
+// Folder where the specification of each server is stored
+servers_folder = "./servers/"
+// How often the docker daemon is checked, reconnecting when it stops responding
+docker_health_interval = "30s"
+// How often the disk usage of the servers is scanned
+disk_scan_interval = "5m"
+// The "userns-remap" user of the docker daemon, if enabled
+userns_remap_user = "dockremap"
+
+// Credentials of private registries, by host
+registry "ghcr.io" {
+    username = "panelmc"
+    password = "token"
+}
+// Also read the credentials from a docker config.json
+docker_config = "/root/.docker/config.json"
+
+// Image tarballs placed in this folder are loaded, for nodes without registry
+// access. Create them with `worker image save -o <file.tar> -presets`
+image_import_folder = "./images/"
+// How often the servers are checked for image updates, "0s" to disable
+image_update_interval = "1h"
+
+// How often the images not used by any preset or server, and the containers
+// without a server, are removed. Only the images pulled, built or imported by
+// the worker are removed. Also available on demand with `worker gc`
+gc_interval = "24h"
+// Only report what would be removed
+gc_dry_run = false
+
+/*
+ * Checks made before creating a new server, rejecting it
+ * when the node doesn't have enough resources.
+ * Omitted values disable the respective check.
+ */
+admission {
+    // Allow allocating up to 150% of the host memory
+    memory_overcommit = 1.5
+    swap_overcommit   = 1
+    // Memory not available to servers
+    reserved_memory   = "1GB"
+    max_servers       = 20
+    // Minimum free space on the data filesystems
+    min_free_disk     = "10GB"
+}
+
+// Ports available for automatic allocation, per host address
+port_pool "0.0.0.0" {
+    ranges = ["25565-25665", "30000-30100"]
+}
 
 // Permission used when creating a new file. e.g. configuration files
 file_permissions = 644
 // Permission used when creating a new folder
 folder_permissions = 744
+
+/*
+ * Alert rules evaluated against the stats of every server.
+ *
+ * Rules with the same name defined in a server preset
+ * take precedence over these.
+ */
+alert "memory" {
+    rule      = "memory_percentage > 95 for 2m"
+    notifiers = ["log", "console", "master"]
+}
+
+alert "cpu" {
+    rule = "cpu_percentage > 300 for 10m"
+}
+
+// Notifiers available to the alert rules, besides "log" and "console".
+notifier "master" {
+    type = "webhook"
+    url  = "http://localhost:8080/alerts"
+}
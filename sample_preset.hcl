@@ -0,0 +1,189 @@
+/*
+ * Server preset, to be placed in the presets folder.
+ *
+ * Values defined here override the default properties
+ * defined in the worker configuration.
+ */
+server_id   = "lobby"
+server_name = "Lobby"
+
+bind {
+    host_dir = "/servers/data/{id}/"
+    volume   = "/data"
+    // Relabel for SELinux, private to this server
+    selinux  = "Z"
+}
+
+bind {
+    host_dir = "/servers/shared/plugins/"
+    volume   = "/plugins"
+    mode     = "ro"
+}
+
+// Docker named volume
+bind {
+    type        = "volume"
+    volume_name = "{id}-world"
+    volume      = "/data/world"
+    driver      = "local"
+}
+
+// In memory filesystem
+bind {
+    type   = "tmpfs"
+    volume = "/tmp"
+    size   = "256MB"
+}
+
+// Owner of the bind host directories, created before the server
+owner {
+    uid = 1000
+    gid = 1000
+}
+
+// Security options, omit for the hardened profile
+security {
+    // "hardened" drops all the capabilities but the ones needed to
+    // switch users on startup, and sets no-new-privileges.
+    // "default" keeps the docker defaults.
+    profile = "hardened"
+    read_only_rootfs = false
+    // user = "1000:1000"
+    // seccomp_profile = "/etc/worker/seccomp.json"
+    // apparmor_profile = "docker-default"
+    // Disable the daemon user namespace remapping
+    // userns_mode = "host"
+}
+
+// Disk quota of the writable bind host directories, excluding the ones
+// shared with other servers
+disk {
+    quota = "10GB"
+    // Warn the server at 90% of the quota, 0 disables the warning
+    warning_percentage = 90
+    // Don't start the server while over quota
+    block_start = true
+}
+
+container_image {
+    id = "itzg/minecraft-server"
+    // "always", "if-not-present" or "never", for preloaded images
+    pull_policy = "if-not-present"
+    // Build the image from a local build context instead, tagged as the id.
+    // Rebuilt whenever the context changes
+    // build {
+    //     context    = "./presets/custom/"
+    //     dockerfile = "Dockerfile"
+    //     args       = { VERSION = "1.16.5" }
+    // }
+}
+
+memory {
+    limit       = "2GB"
+    // Swap on top of the memory limit, or "unlimited"/"disabled".
+    // Older presets defined the memory plus swap total, like docker's
+    // --memory-swap: "limit = 2GB, swap = 2GB" meant no swap, and now
+    // gives 2GB of swap. Use "disabled" or the difference instead.
+    swap        = "1GB"
+    // Soft limit, enforced when the host is low on memory
+    reservation = "1536MB"
+}
+
+network {
+    bind "25565" {}
+
+    // Ranges are mapped to a container range of the same size,
+    // and "tcp+udp" binds both protocols
+    bind "0.0.0.0:19132-19133" {
+        private  = "19132-19133"
+        protocol = "tcp+udp"
+    }
+
+    // Ports allocated from the node port pool, available as
+    // template variables, e.g. {port.game} and {port.query}
+    allocate {
+        count    = 2
+        names    = ["game", "query"]
+        private  = ["25565", "25566"]
+        protocol = "tcp+udp"
+    }
+
+    /*
+     * Docker networks to attach the server to, instead of the default
+     * bridge. Missing networks are created, and removed once unused.
+     */
+    // Network shared by the proxy and its backends
+    docker_network "survival" {
+        // Reachable by the proxy as "lobby-1"
+        aliases = ["lobby-1"]
+    }
+
+    // Network per server, without access to or from the host
+    docker_network "{id}-internal" {
+        internal = true
+    }
+}
+
+// Environment variables, supporting template variables
+env = {
+    SERVER_NAME = "{id}"
+    QUERY_PORT  = "{port.query}"
+}
+
+cpu {
+    // Limit the server to 2 cores
+    cores  = 2
+    // Weight relative to other servers
+    shares = 1024
+    // Pin the server to the given CPUs
+    cpuset = "0-3"
+}
+
+io {
+    // Block IO weight relative to other servers, between 10 and 1000
+    weight = 500
+
+    read_bps "/dev/sda" {
+        rate = "50MB"
+    }
+
+    write_bps "/dev/sda" {
+        rate = "20MB"
+    }
+}
+
+// Maximum number of processes in the container
+pids_limit = 1024
+
+ulimit "nofile" {
+    soft = 65535
+    hard = 65535
+}
+
+// Overrides the "memory" alert defined in the worker configuration
+alert "memory" {
+    rule      = "memory_percentage > 90 for 5m"
+    notifiers = ["log", "console"]
+}
+
+/*
+ * JVM flags generated from the memory limit, injected
+ * into the server as an environment variable or command.
+ */
+jvm {
+    // Percentage of the memory limit, minus the reserved memory, used as heap
+    heap_percentage = 85
+    // Memory reserved for the JVM overhead
+    reserved        = "256MB"
+    // Garbage collector flags, "aikar", "g1" or "none"
+    gc_profile      = "aikar"
+    flags           = ["-Dfile.encoding=UTF-8"]
+
+    // Inject the flags with the environment variable
+    inject = "env"
+    env    = "JVM_OPTS"
+
+    // Or in the startup command
+    // inject  = "command"
+    // command = ["java", "{jvm_flags}", "-jar", "server.jar", "nogui"]
+}
@@ -0,0 +1,122 @@
+package worker
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ContainerSecurity defines the security options of a container.
+type ContainerSecurity struct {
+	// Profile defines the base security options, can be "hardened"
+	// or "default" for the docker defaults. Defaults to "hardened",
+	// also applied without security options: the docker defaults
+	// must be opted in explicitly.
+	Profile string `hcl:"profile,optional" json:"profile,omitempty"`
+	// CapDrop defines the capabilities to drop, e.g. ["ALL"].
+	// Overrides the ones dropped by the profile.
+	CapDrop []string `hcl:"cap_drop,optional" json:"cap_drop,omitempty"`
+	// CapAdd defines the capabilities to add, e.g. ["CHOWN"].
+	// Overrides the ones added by the profile.
+	CapAdd []string `hcl:"cap_add,optional" json:"cap_add,omitempty"`
+	// NoNewPrivileges prevents the processes from gaining new privileges.
+	// Always set by the hardened profile.
+	NoNewPrivileges bool `hcl:"no_new_privileges,optional" json:"no_new_privileges,omitempty"`
+	// ReadOnlyRootfs mounts the root filesystem as read only,
+	// leaving only the binds writable.
+	ReadOnlyRootfs bool `hcl:"read_only_rootfs,optional" json:"read_only_rootfs,omitempty"`
+	// User to run the server as, in the "uid:gid" format
+	User string `hcl:"user,optional" json:"user,omitempty"`
+	// SeccompProfile is the path of a seccomp profile, or "unconfined"
+	SeccompProfile string `hcl:"seccomp_profile,optional" json:"seccomp_profile,omitempty"`
+	// AppArmorProfile is the name of an AppArmor profile loaded on the host,
+	// or "unconfined"
+	AppArmorProfile string `hcl:"apparmor_profile,optional" json:"apparmor_profile,omitempty"`
+	// UsernsMode can be set to "host" to disable the user namespace
+	// remapping of the daemon for this container.
+	UsernsMode string `hcl:"userns_mode,optional" json:"userns_mode,omitempty"`
+}
+
+const (
+	// SecurityProfileHardened drops all the capabilities not needed by
+	// the server images, and prevents gaining new privileges.
+	SecurityProfileHardened = "hardened"
+	// SecurityProfileDefault keeps the docker defaults.
+	SecurityProfileDefault = "default"
+)
+
+// hardenedCapabilities are the capabilities kept by the hardened profile,
+// needed by the images to switch to the server user on startup.
+var hardenedCapabilities = []string{"CHOWN", "DAC_OVERRIDE", "FOWNER", "SETGID", "SETUID", "KILL"}
+
+var (
+	capabilityRegex = regexp.MustCompile(`^(CAP_)?[A-Z_]+$`)
+	userRegex       = regexp.MustCompile(`^[0-9]+(:[0-9]+)?$`)
+	appArmorRegex   = regexp.MustCompile(`^[a-zA-Z0-9_.\-/]+$`)
+)
+
+// Validate checks whether the security options are valid.
+func (s ContainerSecurity) Validate() error {
+	switch s.Profile {
+	case "", SecurityProfileHardened, SecurityProfileDefault:
+	default:
+		return fmt.Errorf("invalid security profile '%s': must be '%s' or '%s'", s.Profile, SecurityProfileHardened, SecurityProfileDefault)
+	}
+
+	for _, capability := range append(append([]string{}, s.CapDrop...), s.CapAdd...) {
+		if !capabilityRegex.MatchString(capability) {
+			return fmt.Errorf("invalid capability '%s'", capability)
+		}
+	}
+
+	if s.User != "" && !userRegex.MatchString(s.User) {
+		return fmt.Errorf("invalid security user '%s': must be in the 'uid:gid' format", s.User)
+	}
+
+	if s.SeccompProfile != "" && s.SeccompProfile != "unconfined" && !filepath.IsAbs(s.SeccompProfile) {
+		return fmt.Errorf("invalid seccomp profile '%s': must be an absolute path or 'unconfined'", s.SeccompProfile)
+	}
+
+	if s.AppArmorProfile != "" && !appArmorRegex.MatchString(s.AppArmorProfile) {
+		return fmt.Errorf("invalid apparmor profile '%s'", s.AppArmorProfile)
+	}
+
+	if s.UsernsMode != "" && s.UsernsMode != "host" {
+		return fmt.Errorf("invalid userns mode '%s': must be 'host'", s.UsernsMode)
+	}
+
+	return nil
+}
+
+// Capabilities returns the capabilities to drop and add,
+// with the defaults of the profile.
+func (s ContainerSecurity) Capabilities() (drop []string, add []string) {
+	drop, add = s.CapDrop, s.CapAdd
+	if s.Hardened() {
+		if drop == nil {
+			drop = []string{"ALL"}
+		}
+		if add == nil {
+			add = hardenedCapabilities
+		}
+	}
+
+	return drop, add
+}
+
+// Hardened returns whether the hardened profile is used.
+func (s ContainerSecurity) Hardened() bool {
+	return s.Profile == "" || s.Profile == SecurityProfileHardened
+}
+
+// NoNewPrivs returns whether the processes are prevented from gaining new privileges.
+func (s ContainerSecurity) NoNewPrivs() bool {
+	return s.NoNewPrivileges || s.Hardened()
+}
+
+// Remapped returns whether the container uses the user namespace
+// remapping of the daemon, if enabled.
+func (s *ContainerSecurity) Remapped() bool {
+	return s == nil || !strings.EqualFold(s.UsernsMode, "host")
+}
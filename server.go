@@ -2,11 +2,32 @@ package worker
 
 // Server represents a game server.
 type Server interface {
+	// ID returns the unique identifier of the server
+	ID() string
+	// Container returns the container the server is running on
+	Container() Container
+
 	Start() error
 
 	Stop() error
 
 	SendCommand(cmd string) error
+
+	// Delete removes the server container, and stops managing it,
+	// releasing its allocated resources
+	Delete() error
+
+	// Resize updates the resources of the server without recreating it.
+	// Changes to the JVM flags generated from the memory limit are applied by Recreate.
+	Resize(update ResourceUpdate) ([]ResourceChange, error)
+
+	// Update recreates the server with the latest image of its tag,
+	// if it changed, restoring its power state
+	Update() (ImageStatus, error)
+
+	// Recreate recreates the server with the given options applied on
+	// top of its current ones, if they changed, restoring its power state
+	Recreate(opts ...ContainerOpts) ([]SpecChange, error)
 }
 
 type server struct {
@@ -29,9 +50,36 @@ const (
 	StatusStopping Status = "stopping"
 )
 
-// NewServer initializes a new Server instance based on the provided Container.
+// admittedContainer is implemented by the containers holding the resources
+// reserved by their admission, until the server is registered.
+type admittedContainer interface {
+	ReleaseAdmission()
+}
+
+// NewServer initializes a new Server instance based on the provided Container,
+// and registers it as managed by this worker.
 func NewServer(container Container) (Server, error) {
-	return &server{container}, nil
+	s := &server{container}
+	err := registerServer(s)
+
+	// Once registered, the server itself accounts for the reserved resources
+	if c, ok := container.(admittedContainer); ok {
+		c.ReleaseAdmission()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *server) ID() string {
+	return s.container.Name()
+}
+
+func (s *server) Container() Container {
+	return s.container
 }
 
 // ServerCreateOptions holds information needed to create a new Server
@@ -44,6 +92,23 @@ type ServerCreateOptions struct {
 	ContainerImage *ContainerImage   `hcl:"container_image,block"`
 	Memory         *ContainerMemory  `hcl:"memory,block"`
 	Network        *ContainerNetwork `hcl:"network,block"`
+	CPU            *ContainerCPU     `hcl:"cpu,block"`
+	IO             *ContainerIO      `hcl:"io,block"`
+	// PidsLimit limits the number of processes in the container
+	PidsLimit int64             `hcl:"pids_limit,optional"`
+	Ulimits   []ContainerUlimit `hcl:"ulimit,block"`
+	JVM       *ContainerJVM     `hcl:"jvm,block"`
+	// Env defines the environment variables of the container.
+	// Values support template variables, e.g. {port.game}.
+	Env map[string]string `hcl:"env,optional"`
+	// Owner of the bind host directories, e.g. uid 1000 for itzg/minecraft-server
+	Owner *ContainerOwner `hcl:"owner,block"`
+	Disk  *ContainerDisk  `hcl:"disk,block"`
+
+	Security *ContainerSecurity `hcl:"security,block"`
+
+	// Alerts defines the alert rules to evaluate against the server stats.
+	Alerts []AlertRule `hcl:"alert,block"`
 }
 
 // ServerPreset represents a preset to be used for Server creation
@@ -0,0 +1,12 @@
+package worker
+
+func (s *server) Delete() (err error) {
+	if err = s.container.Remove(); err != nil {
+		return
+	}
+
+	RemoveServer(s.ID())
+	err = DeleteSpec(s.ID())
+
+	return
+}
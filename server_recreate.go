@@ -0,0 +1,12 @@
+package worker
+
+func (s *server) Recreate(opts ...ContainerOpts) (changes []SpecChange, err error) {
+	updated := s.container.Options()
+	for _, opt := range opts {
+		opt(&updated)
+	}
+
+	changes, err = s.container.Recreate(updated)
+
+	return
+}
@@ -0,0 +1,7 @@
+package worker
+
+func (s *server) Resize(update ResourceUpdate) (changes []ResourceChange, err error) {
+	changes, err = s.container.UpdateResources(update)
+
+	return
+}
@@ -0,0 +1,7 @@
+package worker
+
+func (s *server) Update() (status ImageStatus, err error) {
+	status, err = s.container.UpdateImage()
+
+	return
+}
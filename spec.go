@@ -0,0 +1,135 @@
+package worker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// SpecStore persists the options used to create each container,
+// so they survive worker restarts.
+type SpecStore interface {
+	// SaveSpec saves the options of the container, replacing the previous ones
+	SaveSpec(opts ContainerOptions) error
+	// LoadSpec loads the options of the container with the given name
+	LoadSpec(name string) (ContainerOptions, error)
+	// DeleteSpec deletes the options of the container with the given name
+	DeleteSpec(name string) error
+	// ListSpecs returns the names of the containers with stored options
+	ListSpecs() ([]string, error)
+}
+
+var specs struct {
+	sync.RWMutex
+	store SpecStore
+}
+
+// SetSpecStore sets the store used to persist the container options.
+func SetSpecStore(store SpecStore) {
+	specs.Lock()
+	defer specs.Unlock()
+
+	specs.store = store
+}
+
+// SaveSpec persists the options of a container, if a SpecStore is set.
+func SaveSpec(opts ContainerOptions) error {
+	specs.RLock()
+	defer specs.RUnlock()
+
+	if specs.store == nil {
+		return nil
+	}
+
+	return specs.store.SaveSpec(opts)
+}
+
+// LoadSpec loads the persisted options of a container.
+func LoadSpec(name string) (ContainerOptions, error) {
+	specs.RLock()
+	defer specs.RUnlock()
+
+	if specs.store == nil {
+		return ContainerOptions{}, errNoSpecStore
+	}
+
+	return specs.store.LoadSpec(name)
+}
+
+// DeleteSpec deletes the persisted options of a container, if a SpecStore is set.
+func DeleteSpec(name string) error {
+	specs.RLock()
+	defer specs.RUnlock()
+
+	if specs.store == nil {
+		return nil
+	}
+
+	return specs.store.DeleteSpec(name)
+}
+
+// ListSpecs returns the names of the containers with persisted options,
+// or none if no SpecStore is set.
+func ListSpecs() ([]string, error) {
+	specs.RLock()
+	defer specs.RUnlock()
+
+	if specs.store == nil {
+		return nil, nil
+	}
+
+	return specs.store.ListSpecs()
+}
+
+var errNoSpecStore = errors.New("no spec store configured")
+
+// DiffSpecs returns the options changed from current to updated.
+func DiffSpecs(current, updated ContainerOptions) ([]SpecChange, error) {
+	from, err := specFields(current)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := specFields(updated)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(from)+len(to))
+	for name := range from {
+		names = append(names, name)
+	}
+	for name := range to {
+		if _, ok := from[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := make([]SpecChange, 0)
+	for _, name := range names {
+		if !bytes.Equal(from[name], to[name]) {
+			changes = append(changes, SpecChange{
+				Option: name,
+				From:   string(from[name]),
+				To:     string(to[name]),
+			})
+		}
+	}
+
+	return changes, nil
+}
+
+// specFields returns the json representation of each option.
+func specFields(opts ContainerOptions) (map[string]json.RawMessage, error) {
+	b, err := json.Marshal(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(b, &fields)
+	return fields, err
+}
@@ -0,0 +1,24 @@
+package worker
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Template replaces the template variables in the given string:
+// {id} with the container name, and {port.<name>} with the
+// host port allocated with that name, e.g. {port.game}.
+func (o ContainerOptions) Template(s string) string {
+	if !strings.Contains(s, "{") {
+		return s
+	}
+
+	pairs := []string{"{id}", o.ContainerName}
+	if o.Network != nil {
+		for _, port := range o.Network.Allocated {
+			pairs = append(pairs, "{port."+port.Name+"}", strconv.Itoa(port.Port))
+		}
+	}
+
+	return strings.NewReplacer(pairs...).Replace(s)
+}
@@ -4,12 +4,13 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/PanelMc/worker"
 	"github.com/PanelMc/worker/cmd"
 )
 
 func main() {
-	if err := cmd.Run(); err != nil {
-		fmt.Printf("Error ocurred during execution: %s\n", err.Error())
+	if err := cmd.Execute(os.Args[1:]); err != nil {
+		fmt.Printf("Error ocurred during execution [%s]: %s\n", worker.CodeOf(err), err.Error())
 		os.Exit(1)
 	}
 }